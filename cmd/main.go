@@ -10,6 +10,7 @@ import (
 	zap2 "go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -46,6 +47,7 @@ var (
 type Main struct {
 	Config        *bjcc.Config
 	Controller    lifecycle.Controller
+	Metrics       *metrics.Collector
 	Manager       manager.Manager
 	eventRecorder events.EventRecorder
 }
@@ -53,6 +55,7 @@ type Main struct {
 func init() {
 	utilruntime.Must(corev1.AddToScheme(scheme))
 	utilruntime.Must(appsv1.AddToScheme(scheme))
+	utilruntime.Must(batchv1.AddToScheme(scheme))
 }
 
 // Setup main.
@@ -122,6 +125,7 @@ func Setup() *Main {
 
 	return &Main{
 		Controller: lifecycle.NewController(cfg, pc),
+		Metrics:    pc,
 		Config:     cfg,
 		Manager:    mgr,
 	}
@@ -162,18 +166,38 @@ func (m *Main) Start(runnables ...manager.Runnable) {
 	}
 
 	// setup cron job
-	m.addToManager(cron.Job(envExtender...))
+	cj := cron.Job(envExtender...)
+	m.addToManager(cj)
+	if err := cron.SetupNodeWatcher(m.Manager, cj); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Node")
+		os.Exit(1)
+	}
+	if t, ok := cj.(lifecycle.Trigger); ok {
+		for _, r := range runnables {
+			if ti, ok := r.(inject.Trigger); ok {
+				ti.InjectTrigger(t)
+			}
+		}
+	}
 
 	// Setup a new controller to reconcile ReplicaSets
 	setupLog.Info("Setting up controller")
 
-	if err := (&controller.PodReconciler{
-		Client:     m.Manager.GetClient(),
-		Controller: m.Controller,
-	}).SetupWithManager(m.Manager); err != nil {
+	pr := &controller.PodReconciler{
+		Client:        m.Manager.GetClient(),
+		Controller:    m.Controller,
+		EventRecorder: m.getEventRecorder(),
+	}
+	if err := pr.SetupWithManager(m.Manager); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Pod")
 		os.Exit(1)
 	}
+	if m.Config.IsJobMode() {
+		if err := (&controller.JobReconciler{PodReconciler: pr}).SetupWithManager(m.Manager); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Job")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := m.Manager.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -182,12 +206,16 @@ func (m *Main) Start(runnables ...manager.Runnable) {
 	}
 }
 
+func (m *Main) getEventRecorder() events.EventRecorder {
+	if m.eventRecorder == nil {
+		m.eventRecorder = m.Manager.GetEventRecorder(m.Config.Name)
+	}
+	return m.eventRecorder
+}
+
 func (m *Main) addToManager(r manager.Runnable) {
 	if er, ok := r.(inject.EventRecorder); ok {
-		if m.eventRecorder == nil {
-			m.eventRecorder = m.Manager.GetEventRecorder(m.Config.Name)
-		}
-		er.InjectEventRecorder(m.eventRecorder)
+		er.InjectEventRecorder(m.getEventRecorder())
 	}
 
 	if c, ok := r.(inject.Config); ok {
@@ -196,6 +224,9 @@ func (m *Main) addToManager(r manager.Runnable) {
 	if c, ok := r.(inject.Controller); ok {
 		c.InjectController(m.Controller)
 	}
+	if mc, ok := r.(inject.Metrics); ok {
+		mc.InjectMetrics(m.Metrics)
+	}
 	if r, ok := r.(inject.Reader); ok {
 		r.InjectReader(m.Manager.GetAPIReader())
 	}
@@ -2,15 +2,19 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -28,6 +32,8 @@ const (
 	EnvDevMode = "DEV_MODE"
 	// EnvReportDirectory override for report directory.
 	EnvReportDirectory = "REPORT_DIRECTORY"
+	// EnvAPIToken the bearer token to authenticate api requests.
+	EnvAPIToken = "API_TOKEN"
 
 	// PodTemplateName key of the pod template in the configmap.
 	PodTemplateName = "pod-template.yaml"
@@ -42,8 +48,65 @@ const (
 	LabelPoolSize = "poolSize"
 	// LabelReportHistory reportHistory label.
 	LabelReportHistory = "reportHistory"
+
+	// DefaultExecutionIDFormat the default time layout of the execution IDs (yyyyMMddHHmm).
+	DefaultExecutionIDFormat = "200601021504"
+
+	// DefaultRetryBackoff the default delay before the first retry of a failed job.
+	DefaultRetryBackoff = 10 * time.Second
+
+	// DefaultWebhookRetries the default number of retries of a failed webhook request.
+	DefaultWebhookRetries = 3
+	// DefaultWebhookTimeout the default timeout of a webhook request.
+	DefaultWebhookTimeout = 10 * time.Second
+
+	// DefaultTargetsTimeout the default timeout of a request of a target url.
+	DefaultTargetsTimeout = 30 * time.Second
+
+	// DefaultNodeWatcherCooldown the default min delay between two jobs started for the same node by the node watcher.
+	DefaultNodeWatcherCooldown = 10 * time.Minute
+
+	// WebhookEventStarted an execution was started.
+	WebhookEventStarted = "started"
+	// WebhookEventFinished all pods of an execution are terminated.
+	WebhookEventFinished = "finished"
+	// WebhookEventFailed all pods of an execution are terminated and at least one node failed or did not send a report.
+	WebhookEventFailed = "failed"
+
+	// ConcurrencyPolicyForbid a new execution is not started while the previous one is still running.
+	ConcurrencyPolicyForbid = "Forbid"
+	// ConcurrencyPolicyReplace the running execution is cancelled and replaced by the new one.
+	ConcurrencyPolicyReplace = "Replace"
+	// ConcurrencyPolicyQueue a scheduled execution is started as soon as the running one is done.
+	ConcurrencyPolicyQueue = "Queue"
+
+	// MaintenanceActionSkip a scheduled execution within a maintenance window is skipped.
+	MaintenanceActionSkip = "Skip"
+	// MaintenanceActionDefer a scheduled execution within a maintenance window is started when the window ends.
+	MaintenanceActionDefer = "Defer"
+
+	// JobModePod the jobs run as bare pods.
+	JobModePod = "Pod"
+	// JobModeJob the jobs run as batch/v1 jobs wrapping the job pods.
+	JobModeJob = "Job"
 )
 
+// ConcurrencyPolicies all supported concurrency policies.
+var ConcurrencyPolicies = []string{ConcurrencyPolicyForbid, ConcurrencyPolicyReplace, ConcurrencyPolicyQueue}
+
+// JobModes all supported job modes.
+var JobModes = []string{JobModePod, JobModeJob}
+
+// WebhookEvents all supported webhook events.
+var WebhookEvents = []string{WebhookEventStarted, WebhookEventFinished, WebhookEventFailed}
+
+// taintEffects all effects of node taints.
+var taintEffects = []corev1.TaintEffect{
+	corev1.TaintEffectNoSchedule,
+	corev1.TaintEffectPreferNoSchedule,
+	corev1.TaintEffectNoExecute,
+}
+
 var log = ctrl.Log.WithName("config")
 
 // Get read the config from the configmap.
@@ -93,6 +156,14 @@ func getInternal(namespace string, apiReader client.Reader) (*Config, error) {
 			cfg.StartupDelay = 10 * time.Second
 		}
 
+		if cfg.ExecutionIDFormat == "" {
+			cfg.ExecutionIDFormat = DefaultExecutionIDFormat
+		}
+		// the execution ID is used as label value and directory name
+		if errs := validation.IsValidLabelValue(time.Now().Format(cfg.ExecutionIDFormat)); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid execution id format %q: %s", cfg.ExecutionIDFormat, strings.Join(errs, ", "))
+		}
+
 		cfg.DevMode = IsDevMode()
 		if cfg.DevMode {
 			log.Info("DEV MODE ENABLED!!!")
@@ -103,11 +174,233 @@ func getInternal(namespace string, apiReader client.Reader) (*Config, error) {
 			log.WithValues("env", EnvReportDirectory, "reportDirectory", dir).Info("override report directory from env")
 		}
 
+		if cfg.JobTimeout.Duration < 0 {
+			return nil, fmt.Errorf("job timeout %q must not be negative", cfg.JobTimeout.Duration)
+		}
+
+		if cfg.Retry.MaxAttempts < 0 {
+			return nil, fmt.Errorf("retry max attempts %d must not be negative", cfg.Retry.MaxAttempts)
+		}
+
+		if _, err := cfg.Location(); err != nil {
+			return nil, err
+		}
+
+		if cfg.Jitter.Duration < 0 {
+			return nil, fmt.Errorf("jitter %q must not be negative", cfg.Jitter.Duration)
+		}
+
+		if cfg.StartingDeadline.Duration < 0 {
+			return nil, fmt.Errorf("starting deadline %q must not be negative", cfg.StartingDeadline.Duration)
+		}
+
+		if cfg.NodeWatcher.Cooldown.Duration < 0 {
+			return nil, fmt.Errorf("node watcher cooldown %q must not be negative", cfg.NodeWatcher.Cooldown.Duration)
+		}
+		if cfg.NodeWatcher.Cooldown.Duration == 0 {
+			cfg.NodeWatcher.Cooldown.Duration = DefaultNodeWatcherCooldown
+		}
+
+		if cfg.JobMode == "" {
+			cfg.JobMode = JobModePod
+		}
+		if !slices.Contains(JobModes, cfg.JobMode) {
+			return nil, fmt.Errorf("invalid job mode %q, must be one of %v", cfg.JobMode, JobModes)
+		}
+		if err := cfg.BatchJob.validate(); err != nil {
+			return nil, err
+		}
+
+		if cfg.ConcurrencyPolicy == "" {
+			cfg.ConcurrencyPolicy = ConcurrencyPolicyForbid
+		}
+		if !slices.Contains(ConcurrencyPolicies, cfg.ConcurrencyPolicy) {
+			return nil, fmt.Errorf("invalid concurrency policy %q, must be one of %v",
+				cfg.ConcurrencyPolicy, ConcurrencyPolicies)
+		}
+
+		if err := cfg.JobNodeFilter.validate(cfg.JobNodeSelector); err != nil {
+			return nil, err
+		}
+
+		if err := cfg.Rollout.validate(); err != nil {
+			return nil, err
+		}
+		if err := cfg.Canary.validate(); err != nil {
+			return nil, err
+		}
+		if len(cfg.Schedules) == 0 {
+			if err := validateAwait(cfg.JobTimeout.Duration, &cfg.Rollout, &cfg.Canary); err != nil {
+				return nil, err
+			}
+		}
+		if err := cfg.Targets.validate(); err != nil {
+			return nil, err
+		}
+
+		if err := resolvePodTemplates(cfg.PodTemplates, cm.Data); err != nil {
+			return nil, err
+		}
+
+		if err := resolveSchedules(cfg, cm.Data); err != nil {
+			return nil, err
+		}
+
+		for i := range cfg.Webhooks {
+			if err := cfg.Webhooks[i].validate(); err != nil {
+				return nil, err
+			}
+		}
+
+		names := make(map[string]bool)
+		for i := range cfg.MaintenanceWindows {
+			w := &cfg.MaintenanceWindows[i]
+			if err := w.validate(); err != nil {
+				return nil, err
+			}
+			if names[w.Name] {
+				return nil, fmt.Errorf("duplicate maintenance window name %q", w.Name)
+			}
+			names[w.Name] = true
+		}
+
+		cfg.APIToken = os.Getenv(EnvAPIToken)
+
 		return cfg, nil
 	}
 	return nil, fmt.Errorf("could not find config file %q in configmap %q", ConfigFileName, os.Getenv(EnvConfigMapName))
 }
 
+// resolveSchedules validate the named schedules and set their undefined values from the controller config.
+func resolveSchedules(cfg *Config, templates map[string]string) error {
+	if len(cfg.Schedules) == 0 && cfg.CronExpression != "" {
+		if _, err := CronParser.Parse(cfg.CronExpression); err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", cfg.CronExpression, err)
+		}
+	}
+	names := make(map[string]bool)
+	for i := range cfg.Schedules {
+		s := &cfg.Schedules[i]
+		// the name is used in pod names, labels and report directories
+		if errs := validation.IsDNS1123Label(s.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid schedule name %q: %s", s.Name, strings.Join(errs, ", "))
+		}
+		if names[s.Name] {
+			return fmt.Errorf("duplicate schedule name %q", s.Name)
+		}
+		names[s.Name] = true
+
+		if s.CronExpression == "" {
+			return fmt.Errorf("schedule %q has no cron expression", s.Name)
+		}
+		if _, err := CronParser.Parse(s.CronExpression); err != nil {
+			return fmt.Errorf("invalid cron expression %q of schedule %q: %w", s.CronExpression, s.Name, err)
+		}
+		id := ExecutionIDPrefix(s.Name) + time.Now().Format(cfg.ExecutionIDFormat)
+		if errs := validation.IsValidLabelValue(id); len(errs) > 0 {
+			return fmt.Errorf("invalid execution ids of schedule %q: %s", s.Name, strings.Join(errs, ", "))
+		}
+
+		if s.JobNodeSelector == nil {
+			s.JobNodeSelector = cfg.JobNodeSelector
+		}
+		if s.JobNodeFilter == nil {
+			s.JobNodeFilter = &cfg.JobNodeFilter
+		}
+		if err := s.JobNodeFilter.validate(s.JobNodeSelector); err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+		if s.Rollout == nil {
+			s.Rollout = &cfg.Rollout
+		}
+		if err := s.Rollout.validate(); err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+		if s.Canary == nil {
+			s.Canary = &cfg.Canary
+		}
+		if err := s.Canary.validate(); err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+		if err := validateAwait(cfg.JobTimeout.Duration, s.Rollout, s.Canary); err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+		if s.Targets == nil {
+			s.Targets = &cfg.Targets
+		}
+		if err := s.Targets.validate(); err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+		if s.PodPoolSize == 0 {
+			s.PodPoolSize = cfg.PodPoolSize
+		}
+		if s.ConcurrencyPolicy == "" {
+			s.ConcurrencyPolicy = cfg.ConcurrencyPolicy
+		}
+		if s.ConcurrencyPolicy != "" && !slices.Contains(ConcurrencyPolicies, s.ConcurrencyPolicy) {
+			return fmt.Errorf("invalid concurrency policy %q of schedule %q, must be one of %v",
+				s.ConcurrencyPolicy, s.Name, ConcurrencyPolicies)
+		}
+		if s.StartingDeadline.Duration < 0 {
+			return fmt.Errorf("starting deadline %q of schedule %q must not be negative", s.StartingDeadline.Duration, s.Name)
+		}
+		if s.StartingDeadline.Duration == 0 {
+			s.StartingDeadline = cfg.StartingDeadline
+		}
+		if s.PodTemplate == "" {
+			s.PodTemplate = PodTemplateName
+		}
+		t, ok := templates[s.PodTemplate]
+		if !ok {
+			return fmt.Errorf(
+				"could not find pod template %q of schedule %q in configmap %q",
+				s.PodTemplate,
+				s.Name,
+				os.Getenv(EnvConfigMapName),
+			)
+		}
+		s.JobPodTemplate = t
+		if s.PodTemplates == nil {
+			s.PodTemplates = cfg.PodTemplates
+		} else if err := resolvePodTemplates(s.PodTemplates, templates); err != nil {
+			return fmt.Errorf("schedule %q: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// resolvePodTemplates validate the pod template selectors and set their pod templates from the configmap.
+func resolvePodTemplates(selectors []PodTemplateSelector, templates map[string]string) error {
+	for i := range selectors {
+		p := &selectors[i]
+		if len(p.NodeSelector) == 0 {
+			return fmt.Errorf("pod template %q has no node selector", p.PodTemplate)
+		}
+		if _, err := labels.ValidatedSelectorFromSet(p.NodeSelector); err != nil {
+			return fmt.Errorf("invalid node selector of pod template %q: %w", p.PodTemplate, err)
+		}
+		t, ok := templates[p.PodTemplate]
+		if !ok {
+			return fmt.Errorf(
+				"could not find pod template %q in configmap %q",
+				p.PodTemplate,
+				os.Getenv(EnvConfigMapName),
+			)
+		}
+		p.JobPodTemplate = t
+	}
+	return nil
+}
+
+// validateAwait check that a job timeout is defined if the pods of the rollout waves or of the canary phase are
+// awaited, otherwise a pod that never terminates would block the execution forever.
+func validateAwait(jobTimeout time.Duration, rollout *Rollout, canary *Canary) error {
+	if jobTimeout <= 0 && (rollout.Enabled() || canary.Enabled()) {
+		return errors.New("a job timeout is required for a rollout or a canary phase")
+	}
+	return nil
+}
+
 func IsDevMode() bool {
 	return strings.EqualFold(os.Getenv(EnvDevMode), "true")
 }
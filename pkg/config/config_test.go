@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/google/uuid"
 	gm "go.uber.org/mock/gomock"
@@ -15,6 +16,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	mockclient "github.com/bakito/batch-job-controller/pkg/mocks/client"
+	"github.com/bakito/batch-job-controller/pkg/target"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -52,8 +54,579 @@ var _ = Describe("Config", func() {
 		It("should return a correct name", func() {
 			Ω(c.PodName(node, id)).Should(Equal(fmt.Sprintf("%s-job-%s-%s", name, nodeName, id)))
 		})
+		It("should use the whole identity of a target that is not a node", func() {
+			c.Targets = TargetSource{APIVersion: "v1", Kind: "PersistentVolumeClaim"}
+			Ω(c.PodName("app.data", id)).Should(Equal(fmt.Sprintf("%s-job-app-data-%s", name, id)))
+		})
+	})
+
+	Context("Schedules", func() {
+		var c *Config
+		BeforeEach(func() {
+			c = &Config{
+				CronExpression:  "42 3 * * *",
+				JobNodeSelector: map[string]string{"a": "b"},
+				PodPoolSize:     5,
+				JobPodTemplate:  "kind: Pod",
+			}
+		})
+		It("should return the default schedule if none are defined", func() {
+			Ω(c.AllSchedules()).Should(Equal([]Schedule{{
+				CronExpression:  "42 3 * * *",
+				JobNodeSelector: map[string]string{"a": "b"},
+				JobNodeFilter:   &c.JobNodeFilter,
+				Rollout:         &c.Rollout,
+				Canary:          &c.Canary,
+				Targets:         &c.Targets,
+				PodPoolSize:     5,
+				JobPodTemplate:  "kind: Pod",
+			}}))
+			s, ok := c.ScheduleFor("")
+			Ω(ok).Should(BeTrue())
+			Ω(s.CronExpression).Should(Equal("42 3 * * *"))
+			Ω(c.ScheduleOf("202001021504")).Should(BeEmpty())
+		})
+		It("should return the schedule of an execution id", func() {
+			c.Schedules = []Schedule{{Name: "probe"}, {Name: "probe-all"}}
+			Ω(c.ScheduleOf("probe-202001021504")).Should(Equal("probe"))
+			Ω(c.ScheduleOf("probe-all-202001021504")).Should(Equal("probe-all"))
+			Ω(c.ScheduleOf("202001021504")).Should(BeEmpty())
+			_, ok := c.ScheduleFor("")
+			Ω(ok).Should(BeFalse())
+		})
+		It("should resolve the undefined values of the schedules", func() {
+			c.ExecutionIDFormat = DefaultExecutionIDFormat
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *", PodTemplate: "small.yaml", PodPoolSize: 2},
+				{Name: "nightly", CronExpression: "0 3 * * *", JobNodeSelector: map[string]string{}},
+			}
+			Ω(resolveSchedules(c, map[string]string{
+				PodTemplateName: "kind: Pod",
+				"small.yaml":    "kind: Pod\nmetadata: {}",
+			})).ShouldNot(HaveOccurred())
+
+			Ω(c.Schedules[0].JobPodTemplate).Should(Equal("kind: Pod\nmetadata: {}"))
+			Ω(c.Schedules[0].PodPoolSize).Should(Equal(2))
+			Ω(c.Schedules[0].JobNodeSelector).Should(Equal(map[string]string{"a": "b"}))
+			Ω(c.Schedules[1].JobPodTemplate).Should(Equal("kind: Pod"))
+			Ω(c.Schedules[1].PodPoolSize).Should(Equal(5))
+			Ω(c.Schedules[1].JobNodeSelector).Should(BeEmpty())
+		})
+		It("should resolve the concurrency policy of the schedules", func() {
+			c.ExecutionIDFormat = DefaultExecutionIDFormat
+			c.ConcurrencyPolicy = ConcurrencyPolicyQueue
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *", ConcurrencyPolicy: ConcurrencyPolicyReplace},
+				{Name: "nightly", CronExpression: "0 3 * * *"},
+			}
+			Ω(resolveSchedules(c, map[string]string{PodTemplateName: "kind: Pod"})).ShouldNot(HaveOccurred())
+			Ω(c.Schedules[0].ConcurrencyPolicy).Should(Equal(ConcurrencyPolicyReplace))
+			Ω(c.Schedules[1].ConcurrencyPolicy).Should(Equal(ConcurrencyPolicyQueue))
+
+			c.Schedules = []Schedule{{Name: "hourly", CronExpression: "0 * * * *", ConcurrencyPolicy: "Allow"}}
+			Ω(resolveSchedules(c, map[string]string{PodTemplateName: "kind: Pod"})).
+				Should(MatchError(ContainSubstring(`invalid concurrency policy "Allow"`)))
+		})
+		It("should resolve the starting deadline of the schedules", func() {
+			c.ExecutionIDFormat = DefaultExecutionIDFormat
+			c.StartingDeadline = metav1.Duration{Duration: time.Hour}
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *", StartingDeadline: metav1.Duration{Duration: time.Minute}},
+				{Name: "nightly", CronExpression: "0 3 * * *"},
+			}
+			Ω(resolveSchedules(c, map[string]string{PodTemplateName: "kind: Pod"})).ShouldNot(HaveOccurred())
+			Ω(c.Schedules[0].StartingDeadline.Duration).Should(Equal(time.Minute))
+			Ω(c.Schedules[1].StartingDeadline.Duration).Should(Equal(time.Hour))
+
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *", StartingDeadline: metav1.Duration{Duration: -time.Minute}},
+			}
+			Ω(resolveSchedules(c, map[string]string{PodTemplateName: "kind: Pod"})).
+				Should(MatchError(ContainSubstring("must not be negative")))
+		})
+		It("should resolve the pod templates of the schedules", func() {
+			c.ExecutionIDFormat = DefaultExecutionIDFormat
+			templates := map[string]string{
+				PodTemplateName:         "kind: Pod",
+				"pod-template-arm.yaml": "kind: Pod # arm",
+				"pod-template-gpu.yaml": "kind: Pod # gpu",
+			}
+			c.PodTemplates = []PodTemplateSelector{{
+				NodeSelector:   map[string]string{"kubernetes.io/arch": "arm64"},
+				PodTemplate:    "pod-template-arm.yaml",
+				JobPodTemplate: "kind: Pod # arm",
+			}}
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *"},
+				{Name: "nightly", CronExpression: "0 3 * * *", PodTemplates: []PodTemplateSelector{{
+					NodeSelector: map[string]string{"gpu": "true"},
+					PodTemplate:  "pod-template-gpu.yaml",
+				}}},
+				{Name: "daily", CronExpression: "0 6 * * *", PodTemplates: []PodTemplateSelector{}},
+			}
+			Ω(resolveSchedules(c, templates)).ShouldNot(HaveOccurred())
+			Ω(c.Schedules[0].PodTemplates).Should(Equal(c.PodTemplates))
+			Ω(c.Schedules[1].PodTemplates[0].JobPodTemplate).Should(Equal("kind: Pod # gpu"))
+			Ω(c.Schedules[2].PodTemplates).Should(BeEmpty())
+
+			c.Schedules = []Schedule{{Name: "nightly", CronExpression: "0 3 * * *", PodTemplates: []PodTemplateSelector{{
+				NodeSelector: map[string]string{"gpu": "true"},
+				PodTemplate:  "foo.yaml",
+			}}}}
+			Ω(resolveSchedules(c, templates)).
+				Should(MatchError(ContainSubstring(`schedule "nightly": could not find pod template "foo.yaml"`)))
+		})
+		It("should validate the pod template selectors", func() {
+			templates := map[string]string{"pod-template-arm.yaml": "kind: Pod # arm"}
+			selectors := []PodTemplateSelector{{
+				NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"},
+				PodTemplate:  "pod-template-arm.yaml",
+			}}
+			Ω(resolvePodTemplates(selectors, templates)).ShouldNot(HaveOccurred())
+			Ω(selectors[0].JobPodTemplate).Should(Equal("kind: Pod # arm"))
+
+			Ω(resolvePodTemplates([]PodTemplateSelector{{PodTemplate: "pod-template-arm.yaml"}}, templates)).
+				Should(MatchError(ContainSubstring("has no node selector")))
+			Ω(resolvePodTemplates([]PodTemplateSelector{{
+				NodeSelector: map[string]string{"arch": "arm 64"},
+				PodTemplate:  "pod-template-arm.yaml",
+			}}, templates)).Should(MatchError(ContainSubstring("invalid node selector")))
+		})
+		It("should select the pod template of the first matching node selector", func() {
+			s := Schedule{
+				JobPodTemplate: "default",
+				PodTemplates: []PodTemplateSelector{
+					{NodeSelector: map[string]string{"arch": "arm64"}, JobPodTemplate: "arm"},
+					{NodeSelector: map[string]string{"arch": "arm64", "gpu": "true"}, JobPodTemplate: "arm-gpu"},
+					{NodeSelector: map[string]string{"gpu": "true"}, JobPodTemplate: "gpu"},
+				},
+			}
+			node := func(l map[string]string) target.Target {
+				return target.Target{Kind: target.KindNode, Name: "node", Labels: l}
+			}
+			Ω(s.JobPodTemplateFor(node(map[string]string{"arch": "arm64", "gpu": "true"}))).Should(Equal("arm"))
+			Ω(s.JobPodTemplateFor(node(map[string]string{"arch": "amd64", "gpu": "true"}))).Should(Equal("gpu"))
+			Ω(s.JobPodTemplateFor(node(map[string]string{"arch": "amd64"}))).Should(Equal("default"))
+			Ω(s.JobPodTemplateFor(target.Target{Kind: target.KindEntry, Labels: map[string]string{"arch": "arm64"}})).
+				Should(Equal("default"))
+		})
+		It("should reject invalid schedules", func() {
+			c.ExecutionIDFormat = DefaultExecutionIDFormat
+			templates := map[string]string{PodTemplateName: "kind: Pod"}
+
+			c.Schedules = []Schedule{{Name: "Nightly", CronExpression: "0 3 * * *"}}
+			Ω(resolveSchedules(c, templates)).Should(MatchError(ContainSubstring("invalid schedule name")))
+
+			c.Schedules = []Schedule{{Name: "nightly", CronExpression: "0 3 * * *"}, {Name: "nightly", CronExpression: "0 3 * * *"}}
+			Ω(resolveSchedules(c, templates)).Should(MatchError(ContainSubstring("duplicate schedule name")))
+
+			c.Schedules = []Schedule{{Name: "nightly"}}
+			Ω(resolveSchedules(c, templates)).Should(MatchError(ContainSubstring("has no cron expression")))
+
+			c.Schedules = []Schedule{{Name: "nightly", CronExpression: "0 3 * *"}}
+			Ω(resolveSchedules(c, templates)).Should(MatchError(ContainSubstring("invalid cron expression")))
+
+			c.Schedules = []Schedule{{Name: "nightly", CronExpression: "0 3 * * *", PodTemplate: "foo.yaml"}}
+			Ω(resolveSchedules(c, templates)).Should(MatchError(ContainSubstring(`could not find pod template "foo.yaml"`)))
+
+			c.Schedules = []Schedule{{
+				Name:           "nightly",
+				CronExpression: "0 3 * * *",
+				JobNodeFilter:  &NodeFilter{Include: []string{"Node_A"}},
+			}}
+			Ω(resolveSchedules(c, templates)).Should(MatchError(ContainSubstring(`schedule "nightly": invalid node name`)))
+		})
+		It("should resolve the node filter of the schedules", func() {
+			c.ExecutionIDFormat = DefaultExecutionIDFormat
+			c.JobNodeFilter = NodeFilter{Exclude: []string{"node-a"}}
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *"},
+				{Name: "nightly", CronExpression: "0 3 * * *", JobNodeFilter: &NodeFilter{}},
+			}
+			Ω(resolveSchedules(c, map[string]string{PodTemplateName: "kind: Pod"})).ShouldNot(HaveOccurred())
+			Ω(c.Schedules[0].JobNodeFilter.Exclude).Should(Equal([]string{"node-a"}))
+			Ω(c.Schedules[1].JobNodeFilter.Exclude).Should(BeEmpty())
+		})
+	})
+
+	Context("Rollout", func() {
+		It("should be disabled without topology key", func() {
+			Ω((*Rollout)(nil).Enabled()).Should(BeFalse())
+			Ω((&Rollout{}).Enabled()).Should(BeFalse())
+			Ω((&Rollout{TopologyKey: "topology.kubernetes.io/zone"}).Enabled()).Should(BeTrue())
+		})
+		It("should check the failed percentage of a wave", func() {
+			r := &Rollout{}
+			Ω(r.Exceeded(10, 10)).Should(BeFalse())
+			p := 20
+			r.MaxFailedPercentage = &p
+			Ω(r.Exceeded(2, 10)).Should(BeFalse())
+			Ω(r.Exceeded(3, 10)).Should(BeTrue())
+			p = 0
+			Ω(r.Exceeded(0, 10)).Should(BeFalse())
+			Ω(r.Exceeded(1, 10)).Should(BeTrue())
+		})
+		It("should validate the rollout", func() {
+			r := &Rollout{TopologyKey: "topology.kubernetes.io/zone"}
+			Ω(r.validate()).ShouldNot(HaveOccurred())
+			Ω(r.MaxInFlight).Should(Equal(1))
+
+			Ω((&Rollout{TopologyKey: "-zone"}).validate()).Should(MatchError(ContainSubstring("invalid rollout topology key")))
+			Ω((&Rollout{MaxInFlight: -1}).validate()).Should(MatchError(ContainSubstring("must not be negative")))
+			p := 101
+			Ω((&Rollout{MaxFailedPercentage: &p}).validate()).Should(MatchError(ContainSubstring("between 0 and 100")))
+		})
+		It("should resolve the rollout of the schedules", func() {
+			c := &Config{ExecutionIDFormat: DefaultExecutionIDFormat, Rollout: Rollout{TopologyKey: "zone"}}
+			c.JobTimeout.Duration = time.Hour
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *"},
+				{Name: "nightly", CronExpression: "0 3 * * *", Rollout: &Rollout{MaxInFlight: -1}},
+			}
+			Ω(resolveSchedules(c, map[string]string{PodTemplateName: "kind: Pod"})).
+				Should(MatchError(ContainSubstring(`schedule "nightly": rollout max in flight`)))
+			Ω(c.Schedules[0].Rollout.TopologyKey).Should(Equal("zone"))
+			Ω(c.Schedules[0].Rollout.MaxInFlight).Should(Equal(1))
+		})
 	})
 
+	Context("validateAwait", func() {
+		It("should require a job timeout for a rollout or a canary phase", func() {
+			rollout := &Rollout{TopologyKey: "zone"}
+			canary := &Canary{Nodes: 1}
+			Ω(validateAwait(0, &Rollout{}, &Canary{})).ShouldNot(HaveOccurred())
+			Ω(validateAwait(0, rollout, &Canary{})).Should(MatchError(ContainSubstring("a job timeout is required")))
+			Ω(validateAwait(0, &Rollout{}, canary)).Should(MatchError(ContainSubstring("a job timeout is required")))
+			Ω(validateAwait(time.Hour, rollout, canary)).ShouldNot(HaveOccurred())
+		})
+		It("should require a job timeout for the rollout of a schedule", func() {
+			c := &Config{ExecutionIDFormat: DefaultExecutionIDFormat}
+			c.Schedules = []Schedule{
+				{Name: "hourly", CronExpression: "0 * * * *"},
+				{Name: "nightly", CronExpression: "0 3 * * *", Canary: &Canary{Nodes: 1}},
+			}
+			Ω(resolveSchedules(c, map[string]string{PodTemplateName: "kind: Pod"})).
+				Should(MatchError(ContainSubstring(`schedule "nightly": a job timeout is required`)))
+		})
+	})
+
+	Context("Canary", func() {
+		var nodes []target.Target
+		BeforeEach(func() {
+			nodes = []target.Target{
+				{Kind: target.KindNode, Name: "node-a"},
+				{Kind: target.KindNode, Name: "node-b", Labels: map[string]string{"canary": "true"}},
+				{Kind: target.KindNode, Name: "node-c", Labels: map[string]string{"canary": "true"}},
+			}
+		})
+		It("should be disabled without nodes and selector", func() {
+			Ω((*Canary)(nil).Enabled()).Should(BeFalse())
+			Ω((&Canary{}).Enabled()).Should(BeFalse())
+			Ω((&Canary{Nodes: 1}).Enabled()).Should(BeTrue())
+			Ω((&Canary{NodeSelector: map[string]string{"canary": "true"}}).Enabled()).Should(BeTrue())
+		})
+		It("should split the first nodes", func() {
+			canaries, others := (&Canary{Nodes: 2}).Split(nodes)
+			Ω(canaries).Should(Equal(nodes[:2]))
+			Ω(others).Should(Equal(nodes[2:]))
+		})
+		It("should split the nodes matching the selector", func() {
+			canaries, others := (&Canary{NodeSelector: map[string]string{"canary": "true"}}).Split(nodes)
+			Ω(canaries).Should(Equal(nodes[1:]))
+			Ω(others).Should(Equal(nodes[:1]))
+
+			canaries, others = (&Canary{Nodes: 1, NodeSelector: map[string]string{"canary": "true"}}).Split(nodes)
+			Ω(canaries).Should(Equal(nodes[1:2]))
+			Ω(others).Should(HaveLen(2))
+		})
+		It("should validate the canary", func() {
+			Ω((&Canary{Nodes: 1, NodeSelector: map[string]string{"canary": "true"}}).validate()).ShouldNot(HaveOccurred())
+			Ω((&Canary{Nodes: -1}).validate()).Should(MatchError(ContainSubstring("must not be negative")))
+			Ω((&Canary{NodeSelector: map[string]string{"canary": "-"}}).validate()).
+				Should(MatchError(ContainSubstring("invalid canary node selector")))
+		})
+	})
+
+	Context("TargetSource", func() {
+		It("should target the nodes without kind", func() {
+			Ω((*TargetSource)(nil).IsNodes()).Should(BeTrue())
+			Ω((&TargetSource{}).IsNodes()).Should(BeTrue())
+			Ω((&TargetSource{APIVersion: "v1", Kind: "Namespace"}).IsNodes()).Should(BeFalse())
+		})
+		It("should get the group version kind", func() {
+			t := &TargetSource{APIVersion: "apps/v1", Kind: "Deployment"}
+			Ω(t.GroupVersionKind().Group).Should(Equal("apps"))
+			Ω(t.GroupVersionKind().Version).Should(Equal("v1"))
+			Ω(t.GroupVersionKind().Kind).Should(Equal("Deployment"))
+		})
+		It("should validate the target source", func() {
+			Ω((&TargetSource{}).validate()).ShouldNot(HaveOccurred())
+			Ω((&TargetSource{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "app", Selector: "tier in (db)"}).
+				validate()).ShouldNot(HaveOccurred())
+			Ω((&TargetSource{Selector: "tier=db"}).validate()).Should(MatchError(ContainSubstring("without kind")))
+			Ω((&TargetSource{APIVersion: "v1", Kind: "Node"}).validate()).
+				Should(MatchError(ContainSubstring("nodes are the default targets")))
+			Ω((&TargetSource{Kind: "Namespace"}).validate()).Should(MatchError(ContainSubstring("have no apiVersion")))
+			Ω((&TargetSource{APIVersion: "a/b/c", Kind: "Namespace"}).validate()).
+				Should(MatchError(ContainSubstring("invalid target apiVersion")))
+			Ω((&TargetSource{APIVersion: "v1", Kind: "Pod", Namespace: "App"}).validate()).
+				Should(MatchError(ContainSubstring("invalid target namespace")))
+			Ω((&TargetSource{APIVersion: "v1", Kind: "Pod", Selector: "tier in (db"}).validate()).
+				Should(MatchError(ContainSubstring("invalid target selector")))
+		})
+		It("should validate the target lists", func() {
+			Ω((&TargetSource{Static: []any{"shard-1"}}).IsNodes()).Should(BeFalse())
+			Ω((&TargetSource{Static: []any{"shard-1", map[string]any{"name": "shard-2"}}}).validate()).
+				ShouldNot(HaveOccurred())
+			Ω((&TargetSource{Static: []any{"Shard"}}).validate()).
+				Should(MatchError(ContainSubstring("invalid static targets")))
+			Ω((&TargetSource{ConfigMap: &ConfigMapKey{Name: "shards", Key: "targets.yaml"}}).validate()).
+				ShouldNot(HaveOccurred())
+			Ω((&TargetSource{ConfigMap: &ConfigMapKey{Name: "shards"}}).validate()).
+				Should(MatchError(ContainSubstring("must define a name and a key")))
+			Ω((&TargetSource{URL: "https://targets.example.com", Headers: map[string]string{"a": "b"}}).validate()).
+				ShouldNot(HaveOccurred())
+			Ω((&TargetSource{URL: "ftp://targets.example.com"}).validate()).
+				Should(MatchError(ContainSubstring("scheme must be http or https")))
+			Ω((&TargetSource{URL: "https://targets.example.com", Timeout: metav1.Duration{Duration: -time.Second}}).
+				validate()).Should(MatchError(ContainSubstring("must not be negative")))
+			Ω((&TargetSource{Headers: map[string]string{"a": "b"}}).validate()).
+				Should(MatchError(ContainSubstring("without url")))
+			Ω((&TargetSource{Static: []any{"shard-1"}, URL: "https://targets.example.com"}).validate()).
+				Should(MatchError(ContainSubstring("only one of")))
+		})
+		It("should get the request timeout", func() {
+			Ω((&TargetSource{}).RequestTimeout()).Should(Equal(DefaultTargetsTimeout))
+			Ω((&TargetSource{Timeout: metav1.Duration{Duration: time.Second}}).RequestTimeout()).Should(Equal(time.Second))
+		})
+	})
+
+	Context("BatchJob", func() {
+		It("should be in job mode", func() {
+			Ω(Config{}.IsJobMode()).Should(BeFalse())
+			Ω(Config{JobMode: JobModePod}.IsJobMode()).Should(BeFalse())
+			Ω(Config{JobMode: JobModeJob}.IsJobMode()).Should(BeTrue())
+		})
+		It("should validate the batch job", func() {
+			Ω((&BatchJob{}).validate()).Should(Succeed())
+			Ω((&BatchJob{BackoffLimit: -1}).validate()).Should(MatchError(ContainSubstring("backoff limit -1")))
+			Ω((&BatchJob{ActiveDeadline: metav1.Duration{Duration: -time.Second}}).validate()).
+				Should(MatchError(ContainSubstring("active deadline")))
+			Ω((&BatchJob{TTLAfterFinished: metav1.Duration{Duration: -time.Second}}).validate()).
+				Should(MatchError(ContainSubstring("ttl after finished")))
+		})
+	})
+
+	Context("NodeFilter", func() {
+		var (
+			s    *Schedule
+			node corev1.Node
+		)
+		BeforeEach(func() {
+			s = &Schedule{JobNodeSelector: map[string]string{"role": "worker"}, JobNodeFilter: &NodeFilter{}}
+			node = corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "node-a",
+					Labels: map[string]string{"role": "worker", "zone": "a"},
+				},
+			}
+		})
+		It("should match a node by the node selector", func() {
+			Ω(s.MatchesNode(node)).Should(BeTrue())
+			s.JobNodeFilter = nil
+			Ω(s.MatchesNode(node)).Should(BeTrue())
+			node.Labels["role"] = "infra"
+			Ω(s.MatchesNode(node)).Should(BeFalse())
+		})
+		It("should match a node by label expressions", func() {
+			s.JobNodeFilter.Expressions = []metav1.LabelSelectorRequirement{
+				{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+				{Key: "gpu", Operator: metav1.LabelSelectorOpDoesNotExist},
+			}
+			Ω(s.MatchesNode(node)).Should(BeTrue())
+			node.Labels["gpu"] = "true"
+			Ω(s.MatchesNode(node)).Should(BeFalse())
+			delete(node.Labels, "gpu")
+			node.Labels["zone"] = "c"
+			Ω(s.MatchesNode(node)).Should(BeFalse())
+		})
+		It("should exclude nodes with a matching taint", func() {
+			s.JobNodeFilter.ExcludedTaints = []TaintSelector{{Key: "maintenance", Effect: corev1.TaintEffectNoSchedule}}
+			node.Spec.Taints = []corev1.Taint{{Key: "maintenance", Value: "true", Effect: corev1.TaintEffectNoExecute}}
+			Ω(s.MatchesNode(node)).Should(BeTrue())
+			node.Spec.Taints[0].Effect = corev1.TaintEffectNoSchedule
+			Ω(s.MatchesNode(node)).Should(BeFalse())
+			s.JobNodeFilter.ExcludedTaints[0].Value = "false"
+			Ω(s.MatchesNode(node)).Should(BeTrue())
+		})
+		It("should exclude nodes with a true condition", func() {
+			s.JobNodeFilter.ExcludedConditions = []corev1.NodeConditionType{corev1.NodeDiskPressure}
+			node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeDiskPressure, Status: corev1.ConditionFalse}}
+			Ω(s.MatchesNode(node)).Should(BeTrue())
+			node.Status.Conditions[0].Status = corev1.ConditionTrue
+			Ω(s.MatchesNode(node)).Should(BeFalse())
+		})
+		It("should include and exclude nodes by name", func() {
+			s.JobNodeFilter.Include = []string{"node-a", "node-b"}
+			Ω(s.MatchesNode(node)).Should(BeTrue())
+			node.Name = "node-c"
+			Ω(s.MatchesNode(node)).Should(BeFalse())
+
+			s.JobNodeFilter.Include = nil
+			s.JobNodeFilter.Exclude = []string{"node-c"}
+			Ω(s.MatchesNode(node)).Should(BeFalse())
+		})
+		It("should validate the filter", func() {
+			Ω((&NodeFilter{}).validate(map[string]string{"role": "worker"})).ShouldNot(HaveOccurred())
+			Ω((&NodeFilter{}).validate(map[string]string{"role": "-"})).
+				Should(MatchError(ContainSubstring("invalid node selector")))
+			Ω((&NodeFilter{Expressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: "Has"}}}).validate(nil)).
+				Should(MatchError(ContainSubstring("invalid node selector")))
+			Ω((&NodeFilter{Expressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpIn}}}).
+				validate(nil)).Should(MatchError(ContainSubstring("invalid node selector")))
+			Ω((&NodeFilter{ExcludedTaints: []TaintSelector{{}}}).validate(nil)).
+				Should(MatchError(ContainSubstring("excluded taint has no key")))
+			Ω((&NodeFilter{ExcludedTaints: []TaintSelector{{Key: "a", Effect: "Never"}}}).validate(nil)).
+				Should(MatchError(ContainSubstring(`invalid effect "Never"`)))
+			Ω((&NodeFilter{ExcludedConditions: []corev1.NodeConditionType{""}}).validate(nil)).
+				Should(MatchError(ContainSubstring("excluded node condition must not be empty")))
+			Ω((&NodeFilter{Exclude: []string{"node_a"}}).validate(nil)).
+				Should(MatchError(ContainSubstring(`invalid node name "node_a"`)))
+			Ω((&NodeFilter{Include: []string{"node-a"}, Exclude: []string{"node-a"}}).validate(nil)).
+				Should(MatchError(ContainSubstring(`node "node-a" is included and excluded`)))
+		})
+	})
+
+	Context("Cron", func() {
+		It("should parse expressions with seconds, time zones and descriptors", func() {
+			for _, expr := range []string{"42 3 * * *", "30 42 3 * * *", "CRON_TZ=Europe/Zurich 42 3 * * *", "@hourly"} {
+				_, err := CronParser.Parse(expr)
+				Ω(err).ShouldNot(HaveOccurred(), expr)
+			}
+		})
+		It("should reject an invalid default cron expression", func() {
+			c := &Config{CronExpression: "42 3"}
+			Ω(resolveSchedules(c, nil)).Should(MatchError(ContainSubstring("invalid cron expression")))
+		})
+		It("should use the local time if no time zone is defined", func() {
+			c := &Config{}
+			Ω(c.Location()).Should(Equal(time.Local))
+		})
+		It("should load the time zone", func() {
+			c := &Config{TimeZone: "Europe/Zurich"}
+			loc, err := c.Location()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loc.String()).Should(Equal("Europe/Zurich"))
+		})
+		It("should reject an unknown time zone", func() {
+			c := &Config{TimeZone: "Europe/Nowhere"}
+			_, err := c.Location()
+			Ω(err).Should(MatchError(ContainSubstring("invalid time zone")))
+		})
+	})
+
+	Context("MaintenanceWindow", func() {
+		It("should be active within a recurring window", func() {
+			w := &MaintenanceWindow{
+				Name:           "business",
+				CronExpression: "0 8 * * 1-5",
+				Duration:       metav1.Duration{Duration: 10 * time.Hour},
+			}
+			Ω(w.validate()).ShouldNot(HaveOccurred())
+			Ω(w.Action).Should(Equal(MaintenanceActionSkip))
+
+			// Thursday
+			end, ok := w.EndOf(time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeTrue())
+			Ω(end).Should(Equal(time.Date(2020, 1, 2, 18, 0, 0, 0, time.UTC)))
+
+			end, ok = w.EndOf(time.Date(2020, 1, 2, 8, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeTrue())
+			Ω(end).Should(Equal(time.Date(2020, 1, 2, 18, 0, 0, 0, time.UTC)))
+
+			_, ok = w.EndOf(time.Date(2020, 1, 2, 18, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeFalse())
+			// Saturday
+			_, ok = w.EndOf(time.Date(2020, 1, 4, 12, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeFalse())
+		})
+		It("should be active within an absolute window", func() {
+			w := &MaintenanceWindow{
+				Name:   "freeze",
+				Start:  &metav1.Time{Time: time.Date(2020, 12, 20, 0, 0, 0, 0, time.UTC)},
+				End:    &metav1.Time{Time: time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC)},
+				Action: MaintenanceActionDefer,
+			}
+			Ω(w.validate()).ShouldNot(HaveOccurred())
+
+			end, ok := w.EndOf(time.Date(2020, 12, 24, 0, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeTrue())
+			Ω(end).Should(Equal(w.End.Time))
+			_, ok = w.EndOf(time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeFalse())
+		})
+		It("should return the active window of the config", func() {
+			c := &Config{MaintenanceWindows: []MaintenanceWindow{
+				{Name: "night", CronExpression: "0 0 * * *", Duration: metav1.Duration{Duration: 6 * time.Hour}},
+				{
+					Name:  "freeze",
+					Start: &metav1.Time{Time: time.Date(2020, 12, 20, 0, 0, 0, 0, time.UTC)},
+					End:   &metav1.Time{Time: time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC)},
+				},
+			}}
+			for i := range c.MaintenanceWindows {
+				Ω(c.MaintenanceWindows[i].validate()).ShouldNot(HaveOccurred())
+			}
+			w, _, ok := c.MaintenanceWindowAt(time.Date(2020, 12, 24, 12, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeTrue())
+			Ω(w.Name).Should(Equal("freeze"))
+			w, _, ok = c.MaintenanceWindowAt(time.Date(2020, 11, 24, 3, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeTrue())
+			Ω(w.Name).Should(Equal("night"))
+			_, _, ok = c.MaintenanceWindowAt(time.Date(2020, 11, 24, 12, 0, 0, 0, time.UTC))
+			Ω(ok).Should(BeFalse())
+		})
+		It("should reject invalid windows", func() {
+			start := &metav1.Time{Time: time.Date(2020, 12, 20, 0, 0, 0, 0, time.UTC)}
+			end := &metav1.Time{Time: time.Date(2021, 1, 6, 0, 0, 0, 0, time.UTC)}
+			hour := metav1.Duration{Duration: time.Hour}
+
+			Ω((&MaintenanceWindow{Start: start, End: end}).validate()).Should(MatchError(ContainSubstring("has no name")))
+			Ω((&MaintenanceWindow{Name: "w", Start: start, End: end, Action: "Wait"}).validate()).
+				Should(MatchError(ContainSubstring(`invalid action "Wait"`)))
+			Ω((&MaintenanceWindow{Name: "w", Start: start}).validate()).
+				Should(MatchError(ContainSubstring("must either define a cron expression or a start and end")))
+			Ω((&MaintenanceWindow{Name: "w", CronExpression: "0 0 * * *", Duration: hour, Start: start, End: end}).validate()).
+				Should(MatchError(ContainSubstring("must either define a cron expression or a start and end")))
+			Ω((&MaintenanceWindow{Name: "w", Start: end, End: start}).validate()).
+				Should(MatchError(ContainSubstring("must be after its start")))
+			Ω((&MaintenanceWindow{Name: "w", CronExpression: "0 0 * * *"}).validate()).
+				Should(MatchError(ContainSubstring("must have a positive duration")))
+			Ω((&MaintenanceWindow{Name: "w", CronExpression: "0 0 *", Duration: hour}).validate()).
+				Should(MatchError(ContainSubstring("invalid cron expression")))
+		})
+	})
+
+	Context("Webhook", func() {
+		It("should want all events if none are defined", func() {
+			wh := &Webhook{}
+			Ω(wh.Wants(WebhookEventStarted)).Should(BeTrue())
+			Ω(wh.Wants(WebhookEventFailed)).Should(BeTrue())
+		})
+		It("should only want the defined events", func() {
+			wh := &Webhook{Events: []string{WebhookEventFailed}}
+			Ω(wh.Wants(WebhookEventStarted)).Should(BeFalse())
+			Ω(wh.Wants(WebhookEventFailed)).Should(BeTrue())
+		})
+		It("should reject an url without http scheme", func() {
+			wh := &Webhook{URL: "ftp://example.com"}
+			Ω(wh.validate()).Should(HaveOccurred())
+		})
+		It("should reject an invalid template", func() {
+			wh := &Webhook{URL: "https://example.com", Template: "{{ .Foo"}
+			Ω(wh.validate()).Should(HaveOccurred())
+		})
+	})
 	Context("Get", func() {
 		var (
 			ctx        context.Context
@@ -115,6 +688,211 @@ var _ = Describe("Config", func() {
 				Ω(err.Error()).Should(ContainSubstring("could not read config file"))
 			})
 
+			It("should return an error if the execution id format is invalid", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "executionIDFormat: 2006/01/02",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("invalid execution id format"))
+			})
+
+			It("should return an error if the job timeout is negative", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "jobTimeout: -1m",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("must not be negative"))
+			})
+
+			It("should return an error if the node filter is invalid", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "jobNodeFilter:\n  expressions:\n  - key: zone\n    operator: Has",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("invalid node selector"))
+			})
+
+			It("should return an error if the node watcher cooldown is negative", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "nodeWatcher:\n  cooldown: -1m",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("node watcher cooldown"))
+			})
+
+			It("should return an error if the target source is invalid", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "targets:\n  kind: Namespace",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("have no apiVersion"))
+			})
+
+			It("should return an error if the concurrency policy is invalid", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "concurrencyPolicy: Allow",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("invalid concurrency policy"))
+			})
+
+			It("should return an error if a rollout has no job timeout", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "rollout:\n  topologyKey: topology.kubernetes.io/zone",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("a job timeout is required"))
+			})
+
+			It("should return an error if the job mode is invalid", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "jobMode: CronJob",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring(`invalid job mode "CronJob"`))
+			})
+
+			It("should return an error if the time zone is unknown", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName:  "timeZone: Europe/Nowhere",
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("invalid time zone"))
+			})
+
+			It("should return an error if the pod template of a node group is not found", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName: `
+podTemplates:
+  - nodeSelector:
+      kubernetes.io/arch: arm64
+    podTemplate: pod-template-arm64.yaml`,
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring(`could not find pod template "pod-template-arm64.yaml"`))
+			})
+
+			It("should return an error if a webhook is invalid", func() {
+				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+						cm.Data = map[string]string{
+							ConfigFileName: `
+webhooks:
+  - url: https://chat.example.com/hook
+    events: [started, done]`,
+							PodTemplateName: "kind: Pod",
+						}
+						return nil
+					})
+				mockReader.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+					Return(errors.New("pod not found"))
+
+				c, err := getInternal(namespace, mockReader)
+				Ω(c).Should(BeNil())
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring(`invalid event "done"`))
+			})
+
 			It("should return an error if no pod template config is found", func() {
 				mockReader.EXPECT().Get(ctx, cmKey, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
 					Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
@@ -150,6 +928,10 @@ var _ = Describe("Config", func() {
 
 				Ω(c.JobPodTemplate).Should(Equal("kind: Pod"))
 				Ω(c.Owner).Should(BeNil())
+				Ω(c.ExecutionIDFormat).Should(Equal(DefaultExecutionIDFormat))
+				Ω(c.ConcurrencyPolicy).Should(Equal(ConcurrencyPolicyForbid))
+				Ω(c.JobMode).Should(Equal(JobModePod))
+				Ω(c.NodeWatcher.Cooldown.Duration).Should(Equal(DefaultNodeWatcherCooldown))
 			})
 
 			It("should return a config with owner", func() {
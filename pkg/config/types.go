@@ -4,14 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/robfig/cron/v3"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	"github.com/bakito/batch-job-controller/pkg/target"
+
+	// embed the time zone database, the controller image may not provide one
+	_ "time/tzdata"
 )
 
 const (
@@ -30,10 +43,12 @@ type Config struct {
 	ReportDirectory       string                        `json:"reportDirectory"`
 	ReportHistory         int                           `json:"reportHistory"`
 	PodPoolSize           int                           `json:"podPoolSize"`
-	RunOnStartup          bool                          `json:"runOnStartup"`
-	StartupDelay          time.Duration                 `json:"startupDelay"`
-	Metrics               Metrics                       `json:"metrics"`
-	HealthProbePort       int                           `json:"healthProbePort"`
+	// RunOnStartup start all schedules on startup. Deprecated, use StartingDeadline to start missed executions instead
+	RunOnStartup bool `json:"runOnStartup"`
+	// StartupDelay the delay of the start on startup. Deprecated, use StartingDeadline to start missed executions instead
+	StartupDelay    time.Duration `json:"startupDelay"`
+	Metrics         Metrics       `json:"metrics"`
+	HealthProbePort int           `json:"healthProbePort"`
 	// LatestMetricsLabel if true, each result metric is also created with executionID=latest
 	LatestMetricsLabel bool           `json:"latestMetricsLabel"`
 	Custom             map[string]any `json:"custom"`
@@ -44,20 +59,140 @@ type Config struct {
 	LeaderElectionResourceLock string `json:"leaderElectionResourceLock,omitempty"`
 	// SavePodLog if enabled, pod logs are saved along other with other job files
 	SavePodLog bool `json:"savePodLog"`
+	// ExecutionIDFormat the time layout used to create the execution IDs. Default is DefaultExecutionIDFormat
+	ExecutionIDFormat string `json:"executionIDFormat"`
+	// JobTimeout the max duration a job pod may take to terminate, it is deleted if exceeded. 0 disables the timeout
+	JobTimeout metav1.Duration `json:"jobTimeout"`
+	// Retry the retry policy of failed jobs
+	Retry RetryPolicy `json:"retry"`
+	// ReportURL the external base URL of the static file server, used to link the reports of an execution
+	ReportURL string `json:"reportURL,omitempty"`
+	// Webhooks the webhooks to be notified on execution lifecycle events
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+	// Schedules named schedules, if defined they replace the schedule defined by cronExpression
+	Schedules []Schedule `json:"schedules,omitempty"`
+	// TimeZone the IANA time zone the cron expressions are evaluated in. Default is the local time of the controller
+	TimeZone string `json:"timeZone,omitempty"`
+	// Jitter the max random delay of a scheduled execution, to not start all controllers at the same second
+	Jitter metav1.Duration `json:"jitter"`
+	// ConcurrencyPolicy how to handle a new execution while the previous one is running. Default is ConcurrencyPolicyForbid
+	ConcurrencyPolicy string `json:"concurrencyPolicy,omitempty"`
+	// StartingDeadline the max delay a missed scheduled execution is started with on startup. 0 disables catching up
+	StartingDeadline metav1.Duration `json:"startingDeadline"`
+	// MaintenanceWindows the time ranges no scheduled execution is started in
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// NodeWatcher runs jobs on nodes that become ready between scheduled executions
+	NodeWatcher NodeWatcher `json:"nodeWatcher"`
+	// JobNodeFilter further limits the nodes matching the JobNodeSelector
+	JobNodeFilter NodeFilter `json:"jobNodeFilter"`
+	// Rollout rolls out the executions in waves of nodes grouped by a topology label
+	Rollout Rollout `json:"rollout"`
+	// Canary runs the jobs on canary nodes first, the other nodes are only started if all canaries succeeded
+	Canary Canary `json:"canary"`
+	// Targets the objects the jobs fan out over, one job per target. Default are the nodes
+	Targets TargetSource `json:"targets"`
+	// JobMode how the jobs run, as bare pods or as batch/v1 jobs. Default is JobModePod
+	JobMode string `json:"jobMode,omitempty"`
+	// BatchJob the settings of the batch/v1 jobs of JobModeJob
+	BatchJob BatchJob `json:"batchJob"`
+	// PodTemplates alternative pod templates of node groups, the first matching one is used
+	PodTemplates []PodTemplateSelector `json:"podTemplates,omitempty"`
 
 	Namespace      string         `json:"-"`
 	JobPodTemplate string         `json:"-"`
 	Owner          runtime.Object `json:"-"`
 	DevMode        bool           `json:"-"`
+	// APIToken the bearer token required by the api endpoints, read from env
+	APIToken string `json:"-"`
+}
+
+// CronParser the parser of the cron expressions. A leading seconds field, descriptors like '@hourly' and
+// 'CRON_TZ=' prefixes are supported.
+var CronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Location get the location of the configured time zone, the local time if none is configured.
+func (cfg *Config) Location() (*time.Location, error) {
+	if cfg.TimeZone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", cfg.TimeZone, err)
+	}
+	return loc, nil
 }
 
-// PodName get the name of the pod.
-func (cfg *Config) PodName(nodeName, id string) string {
-	nameParts := strings.Split(nodeName, ".")
-	podName := fmt.Sprintf("%s-job-%s-%s", cfg.Name, nameParts[0], id)
+// PodName get the name of the job pod of a target of an execution. The short name is used for nodes,
+// the dots of the identity of other targets are replaced.
+func (cfg *Config) PodName(target, id string) string {
+	name := strings.Split(target, ".")[0]
+	if sc, ok := cfg.ScheduleFor(cfg.ScheduleOf(id)); ok && !sc.Targets.IsNodes() {
+		name = strings.ReplaceAll(target, ".", "-")
+	}
+	podName := fmt.Sprintf("%s-job-%s-%s", cfg.Name, name, id)
 	return podName
 }
 
+// AllSchedules get the named schedules, or the default schedule defined by the controller config if none are defined.
+func (cfg *Config) AllSchedules() []Schedule {
+	if len(cfg.Schedules) > 0 {
+		return cfg.Schedules
+	}
+	return []Schedule{{
+		CronExpression:    cfg.CronExpression,
+		JobNodeSelector:   cfg.JobNodeSelector,
+		JobNodeFilter:     &cfg.JobNodeFilter,
+		Rollout:           &cfg.Rollout,
+		Canary:            &cfg.Canary,
+		Targets:           &cfg.Targets,
+		PodTemplates:      cfg.PodTemplates,
+		PodPoolSize:       cfg.PodPoolSize,
+		ConcurrencyPolicy: cfg.ConcurrencyPolicy,
+		StartingDeadline:  cfg.StartingDeadline,
+		JobPodTemplate:    cfg.JobPodTemplate,
+	}}
+}
+
+// ScheduleFor get the schedule with the given name.
+func (cfg *Config) ScheduleFor(name string) (Schedule, bool) {
+	for _, s := range cfg.AllSchedules() {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Schedule{}, false
+}
+
+// ScheduleOf get the name of the schedule an execution id belongs to, empty for the default schedule.
+func (cfg *Config) ScheduleOf(executionID string) string {
+	var name string
+	for _, s := range cfg.Schedules {
+		// the longest prefix wins, if one schedule name is the prefix of another
+		if strings.HasPrefix(executionID, ExecutionIDPrefix(s.Name)) && len(s.Name) > len(name) {
+			name = s.Name
+		}
+	}
+	return name
+}
+
+// MaintenanceWindowAt get the maintenance window the given time is within and the end of the window.
+func (cfg *Config) MaintenanceWindowAt(t time.Time) (*MaintenanceWindow, time.Time, bool) {
+	for i := range cfg.MaintenanceWindows {
+		w := &cfg.MaintenanceWindows[i]
+		if end, ok := w.EndOf(t); ok {
+			return w, end, true
+		}
+	}
+	return nil, time.Time{}, false
+}
+
+// IsJobMode returns true if the jobs run as batch/v1 jobs.
+func (cfg Config) IsJobMode() bool {
+	return cfg.JobMode == JobModeJob
+}
+
 func (cfg *Config) HealthProbeBindAddress() string {
 	if cfg.HealthProbePort == 0 {
 		return defaultHealthBindAddress
@@ -101,6 +236,520 @@ func (m *Metrics) BindAddress() string {
 	return fmt.Sprintf(":%d", m.Port)
 }
 
+// Schedule config of a named schedule. Undefined values are taken from the controller config.
+type Schedule struct {
+	// Name the name of the schedule, it is used as prefix of the execution ids
+	Name           string `json:"name"`
+	CronExpression string `json:"cronExpression"`
+	// JobNodeSelector node selector labels to define in which nodes to run the jobs
+	JobNodeSelector map[string]string `json:"jobNodeSelector,omitempty"`
+	// JobNodeFilter further limits the nodes matching the JobNodeSelector
+	JobNodeFilter *NodeFilter `json:"jobNodeFilter,omitempty"`
+	// Rollout the rollout of the executions of the schedule
+	Rollout *Rollout `json:"rollout,omitempty"`
+	// Canary the canary phase of the executions of the schedule
+	Canary *Canary `json:"canary,omitempty"`
+	// Targets the objects the jobs of the schedule fan out over
+	Targets *TargetSource `json:"targets,omitempty"`
+	// PodTemplate the key of the pod template in the configmap. Default is PodTemplateName
+	PodTemplate string `json:"podTemplate,omitempty"`
+	// PodTemplates alternative pod templates of node groups of the schedule. Default are the PodTemplates of the config
+	PodTemplates []PodTemplateSelector `json:"podTemplates,omitempty"`
+	// PodPoolSize the number of concurrent job pods
+	PodPoolSize int `json:"podPoolSize,omitempty"`
+	// ConcurrencyPolicy how to handle a new execution while the previous one is running
+	ConcurrencyPolicy string `json:"concurrencyPolicy,omitempty"`
+	// StartingDeadline the max delay a missed scheduled execution is started with on startup
+	StartingDeadline metav1.Duration `json:"startingDeadline,omitempty"`
+
+	JobPodTemplate string `json:"-"`
+}
+
+// MatchesNode check if the jobs of the schedule run on the node. The readiness of the node is not checked.
+func (s *Schedule) MatchesNode(node corev1.Node) bool {
+	f := s.JobNodeFilter
+	if f == nil {
+		f = &NodeFilter{}
+	}
+	return f.matches(s.JobNodeSelector, node)
+}
+
+// JobPodTemplateFor get the pod template of the target. The template of the first pod template selector matching the
+// labels of a node is used, the JobPodTemplate of the schedule if none matches or the target is not a node.
+func (s *Schedule) JobPodTemplateFor(t target.Target) string {
+	if t.IsNode() {
+		for _, p := range s.PodTemplates {
+			if labels.SelectorFromSet(p.NodeSelector).Matches(labels.Set(t.Labels)) {
+				return p.JobPodTemplate
+			}
+		}
+	}
+	return s.JobPodTemplate
+}
+
+// PodTemplateSelector selects an alternative pod template for the nodes matching the node selector.
+type PodTemplateSelector struct {
+	// NodeSelector labels of the nodes the pod template is used for
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// PodTemplate the key of the pod template in the configmap
+	PodTemplate string `json:"podTemplate"`
+
+	JobPodTemplate string `json:"-"`
+}
+
+// NodeFilter limits the nodes matching the job node selector.
+type NodeFilter struct {
+	// Expressions label selector requirements the nodes must match (In, NotIn, Exists, DoesNotExist)
+	Expressions []metav1.LabelSelectorRequirement `json:"expressions,omitempty"`
+	// ExcludedTaints nodes with a taint matching one of these are excluded
+	ExcludedTaints []TaintSelector `json:"excludedTaints,omitempty"`
+	// ExcludedConditions nodes with one of these conditions being true are excluded, e.g. DiskPressure
+	ExcludedConditions []corev1.NodeConditionType `json:"excludedConditions,omitempty"`
+	// Include the names of the nodes the jobs are limited to, all nodes if empty
+	Include []string `json:"include,omitempty"`
+	// Exclude the names of the nodes the jobs never run on
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// TaintSelector selects node taints by key and optionally by value and effect.
+type TaintSelector struct {
+	Key string `json:"key"`
+	// Value the value of the taint, any value if empty
+	Value string `json:"value,omitempty"`
+	// Effect the effect of the taint, any effect if empty
+	Effect corev1.TaintEffect `json:"effect,omitempty"`
+}
+
+// selector get the label selector of the node selector labels and the expressions of the filter.
+func (f *NodeFilter) selector(matchLabels map[string]string) (labels.Selector, error) {
+	return metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
+		MatchLabels:      matchLabels,
+		MatchExpressions: f.Expressions,
+	})
+}
+
+func (f *NodeFilter) matches(matchLabels map[string]string, node corev1.Node) bool {
+	selector, err := f.selector(matchLabels)
+	if err != nil || !selector.Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if len(f.Include) > 0 && !slices.Contains(f.Include, node.Name) {
+		return false
+	}
+	if slices.Contains(f.Exclude, node.Name) {
+		return false
+	}
+	for _, t := range node.Spec.Taints {
+		for _, ts := range f.ExcludedTaints {
+			if ts.matches(t) {
+				return false
+			}
+		}
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Status == corev1.ConditionTrue && slices.Contains(f.ExcludedConditions, c.Type) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *NodeFilter) validate(matchLabels map[string]string) error {
+	if _, err := f.selector(matchLabels); err != nil {
+		return fmt.Errorf("invalid node selector: %w", err)
+	}
+	for _, ts := range f.ExcludedTaints {
+		if ts.Key == "" {
+			return errors.New("excluded taint has no key")
+		}
+		if ts.Effect != "" && !slices.Contains(taintEffects, ts.Effect) {
+			return fmt.Errorf("invalid effect %q of excluded taint %q, must be one of %v", ts.Effect, ts.Key, taintEffects)
+		}
+	}
+	for _, c := range f.ExcludedConditions {
+		if c == "" {
+			return errors.New("excluded node condition must not be empty")
+		}
+	}
+	for _, n := range slices.Concat(f.Include, f.Exclude) {
+		if errs := validation.IsDNS1123Subdomain(n); len(errs) > 0 {
+			return fmt.Errorf("invalid node name %q: %s", n, strings.Join(errs, ", "))
+		}
+		if slices.Contains(f.Include, n) && slices.Contains(f.Exclude, n) {
+			return fmt.Errorf("node %q is included and excluded", n)
+		}
+	}
+	return nil
+}
+
+func (ts *TaintSelector) matches(t corev1.Taint) bool {
+	return t.Key == ts.Key && (ts.Value == "" || t.Value == ts.Value) && (ts.Effect == "" || t.Effect == ts.Effect)
+}
+
+// Rollout config of executions rolled out in waves. Each wave contains up to MaxInFlight nodes of each group of nodes
+// with the same value of the topology label, the next wave is started once all pods of the wave are terminated.
+type Rollout struct {
+	// TopologyKey the label the nodes are grouped by, e.g. topology.kubernetes.io/zone. The rollout is disabled if empty
+	TopologyKey string `json:"topologyKey,omitempty"`
+	// MaxInFlight the max number of nodes of a group in a wave. Default is 1
+	MaxInFlight int `json:"maxInFlight,omitempty"`
+	// MaxFailedPercentage the max percentage of failed nodes of a wave, the remaining waves are halted if exceeded.
+	// Default is 100 (never halted)
+	MaxFailedPercentage *int `json:"maxFailedPercentage,omitempty"`
+}
+
+// Enabled returns true if the executions are rolled out in waves.
+func (r *Rollout) Enabled() bool {
+	return r != nil && r.TopologyKey != ""
+}
+
+// Exceeded returns true if the failed nodes of a wave exceed the max failed percentage.
+func (r *Rollout) Exceeded(failed, nodes int) bool {
+	if r.MaxFailedPercentage == nil || nodes == 0 {
+		return false
+	}
+	return failed*100 > *r.MaxFailedPercentage*nodes
+}
+
+func (r *Rollout) validate() error {
+	if r.TopologyKey != "" {
+		if errs := validation.IsQualifiedName(r.TopologyKey); len(errs) > 0 {
+			return fmt.Errorf("invalid rollout topology key %q: %s", r.TopologyKey, strings.Join(errs, ", "))
+		}
+	}
+	if r.MaxInFlight < 0 {
+		return fmt.Errorf("rollout max in flight %d must not be negative", r.MaxInFlight)
+	}
+	if r.MaxInFlight == 0 {
+		r.MaxInFlight = 1
+	}
+	if p := r.MaxFailedPercentage; p != nil && (*p < 0 || *p > 100) {
+		return fmt.Errorf("rollout max failed percentage %d must be between 0 and 100", *p)
+	}
+	return nil
+}
+
+// Canary config of the canary phase of an execution. The jobs of the canary nodes are started first, the jobs of the
+// other nodes are only started if all canary pods succeeded and sent a report.
+type Canary struct {
+	// Nodes the number of canary nodes, the max number of canary nodes if a node selector is defined
+	Nodes int `json:"nodes,omitempty"`
+	// NodeSelector labels of the canary nodes
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// Enabled returns true if the executions have a canary phase.
+func (c *Canary) Enabled() bool {
+	return c != nil && (c.Nodes > 0 || len(c.NodeSelector) > 0)
+}
+
+// Split split the targets into the canary targets and the other targets.
+func (c *Canary) Split(targets []target.Target) (canaries, others []target.Target) {
+	selector := labels.SelectorFromSet(c.NodeSelector)
+	for _, n := range targets {
+		if (c.Nodes == 0 || len(canaries) < c.Nodes) && selector.Matches(labels.Set(n.Labels)) {
+			canaries = append(canaries, n)
+		} else {
+			others = append(others, n)
+		}
+	}
+	return canaries, others
+}
+
+func (c *Canary) validate() error {
+	if c.Nodes < 0 {
+		return fmt.Errorf("canary nodes %d must not be negative", c.Nodes)
+	}
+	if _, err := labels.ValidatedSelectorFromSet(c.NodeSelector); err != nil {
+		return fmt.Errorf("invalid canary node selector: %w", err)
+	}
+	return nil
+}
+
+// TargetSource the source of the targets the jobs fan out over, either objects of a kind, a static list, a list in a
+// configmap or a list responded by an url. The nodes are the targets if no source is defined.
+type TargetSource struct {
+	// APIVersion the api version of the targets, e.g. v1 or apps/v1
+	APIVersion string `json:"apiVersion,omitempty"`
+	// Kind the kind of the targets, e.g. Namespace or PersistentVolumeClaim
+	Kind string `json:"kind,omitempty"`
+	// Namespace the namespace of namespaced targets, all namespaces if empty
+	Namespace string `json:"namespace,omitempty"`
+	// Selector the label selector of the targets
+	Selector string `json:"selector,omitempty"`
+	// Static a static target list. An entry is the name of a target or an object with a name and further fields
+	Static []any `json:"static,omitempty"`
+	// ConfigMap a key of a configmap in the namespace of the controller with a yaml or json target list
+	ConfigMap *ConfigMapKey `json:"configMap,omitempty"`
+	// URL the url of an http endpoint responding a json target list
+	URL string `json:"url,omitempty"`
+	// Headers additional headers of the requests of the url
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout the timeout of a request of the url. Default is DefaultTargetsTimeout
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ConfigMapKey a key of a configmap.
+type ConfigMapKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// IsNodes returns true if the nodes are the targets.
+func (t *TargetSource) IsNodes() bool {
+	return t == nil || (t.Kind == "" && t.Static == nil && t.ConfigMap == nil && t.URL == "")
+}
+
+// GroupVersionKind get the group version kind of the targets.
+func (t *TargetSource) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(t.APIVersion, t.Kind)
+}
+
+// RequestTimeout get the timeout of a request of the url.
+func (t *TargetSource) RequestTimeout() time.Duration {
+	if t.Timeout.Duration > 0 {
+		return t.Timeout.Duration
+	}
+	return DefaultTargetsTimeout
+}
+
+func (t *TargetSource) validate() error {
+	var sources int
+	for _, defined := range []bool{t.Kind != "", t.Static != nil, t.ConfigMap != nil, t.URL != ""} {
+		if defined {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return errors.New("only one of kind, static, configMap or url may define the targets")
+	}
+	if t.Kind == "" && (t.APIVersion != "" || t.Namespace != "" || t.Selector != "") {
+		return errors.New("targets without kind must not define apiVersion, namespace or selector")
+	}
+	if t.URL == "" && (len(t.Headers) > 0 || t.Timeout.Duration != 0) {
+		return errors.New("targets without url must not define headers or timeout")
+	}
+	switch {
+	case t.Kind != "":
+		return t.validateKind()
+	case t.Static != nil:
+		if _, err := target.FromEntries(t.Static); err != nil {
+			return fmt.Errorf("invalid static targets: %w", err)
+		}
+	case t.ConfigMap != nil:
+		if t.ConfigMap.Name == "" || t.ConfigMap.Key == "" {
+			return errors.New("the target configmap must define a name and a key")
+		}
+	case t.URL != "":
+		u, err := url.Parse(t.URL)
+		if err != nil {
+			return fmt.Errorf("invalid target url %q: %w", t.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid target url %q: scheme must be http or https", t.URL)
+		}
+		if t.Timeout.Duration < 0 {
+			return fmt.Errorf("target url timeout %q must not be negative", t.Timeout.Duration)
+		}
+	}
+	return nil
+}
+
+func (t *TargetSource) validateKind() error {
+	if t.Kind == target.KindNode {
+		return errors.New("nodes are the default targets, use no target kind to select nodes")
+	}
+	if t.APIVersion == "" {
+		return fmt.Errorf("targets of kind %q have no apiVersion", t.Kind)
+	}
+	if _, err := schema.ParseGroupVersion(t.APIVersion); err != nil {
+		return fmt.Errorf("invalid target apiVersion %q: %w", t.APIVersion, err)
+	}
+	if t.Namespace != "" {
+		if errs := validation.IsDNS1123Label(t.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid target namespace %q: %s", t.Namespace, strings.Join(errs, ", "))
+		}
+	}
+	if _, err := labels.Parse(t.Selector); err != nil {
+		return fmt.Errorf("invalid target selector: %w", err)
+	}
+	return nil
+}
+
+// ExecutionIDPrefix get the prefix of the execution ids of a schedule.
+func ExecutionIDPrefix(schedule string) string {
+	if schedule == "" {
+		return ""
+	}
+	return schedule + "-"
+}
+
+// BatchJob settings of the batch/v1 jobs of JobModeJob.
+type BatchJob struct {
+	// BackoffLimit the number of retries of the job pod by kubernetes. Default is 0, the retry policy still applies
+	BackoffLimit int32 `json:"backoffLimit"`
+	// ActiveDeadline the max duration of the job enforced by kubernetes. 0 disables the deadline
+	ActiveDeadline metav1.Duration `json:"activeDeadline"`
+	// TTLAfterFinished the duration a finished job is deleted by kubernetes after. 0 keeps finished jobs
+	TTLAfterFinished metav1.Duration `json:"ttlAfterFinished"`
+}
+
+func (b *BatchJob) validate() error {
+	if b.BackoffLimit < 0 {
+		return fmt.Errorf("batch job backoff limit %d must not be negative", b.BackoffLimit)
+	}
+	if b.ActiveDeadline.Duration < 0 {
+		return fmt.Errorf("batch job active deadline %q must not be negative", b.ActiveDeadline.Duration)
+	}
+	if b.TTLAfterFinished.Duration < 0 {
+		return fmt.Errorf("batch job ttl after finished %q must not be negative", b.TTLAfterFinished.Duration)
+	}
+	return nil
+}
+
+// RetryPolicy config of failed jobs.
+type RetryPolicy struct {
+	// MaxAttempts the max number of attempts of a job including the first one. 0 or 1 disables retries
+	MaxAttempts int `json:"maxAttempts"`
+	// Backoff the delay before the first retry, it is doubled for each further retry. Default is DefaultRetryBackoff
+	Backoff metav1.Duration `json:"backoff"`
+}
+
+// BackoffFor get the delay before the given attempt.
+func (r *RetryPolicy) BackoffFor(attempt int) time.Duration {
+	backoff := r.Backoff.Duration
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// NodeWatcher config of the watcher of nodes that become ready.
+type NodeWatcher struct {
+	// Enabled start the job of a node as soon as it becomes ready
+	Enabled bool `json:"enabled"`
+	// Cooldown the min delay between two jobs started for the same node. Default is DefaultNodeWatcherCooldown
+	Cooldown metav1.Duration `json:"cooldown"`
+}
+
+// Webhook config.
+type Webhook struct {
+	URL string `json:"url"`
+	// Events the events to notify, all events if empty
+	Events []string `json:"events,omitempty"`
+	// Headers additional headers of the request
+	Headers map[string]string `json:"headers,omitempty"`
+	// Template go template of the request body, the payload is sent as json if empty
+	Template string `json:"template,omitempty"`
+	// Retries the number of retries if the request fails. Default is DefaultWebhookRetries
+	Retries *int `json:"retries,omitempty"`
+	// Timeout the timeout of a request. Default is DefaultWebhookTimeout
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Wants returns true if the webhook is to be notified for the given event.
+func (w *Webhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+func (w *Webhook) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url %q: %w", w.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook url %q: scheme must be http or https", w.URL)
+	}
+	for _, e := range w.Events {
+		if !slices.Contains(WebhookEvents, e) {
+			return fmt.Errorf("invalid event %q of webhook %q, must be one of %v", e, w.URL, WebhookEvents)
+		}
+	}
+	if w.Template != "" {
+		if _, err := template.New("webhook").Parse(w.Template); err != nil {
+			return fmt.Errorf("invalid template of webhook %q: %w", w.URL, err)
+		}
+	}
+	if w.Retries != nil && *w.Retries < 0 {
+		return fmt.Errorf("retries of webhook %q must not be negative", w.URL)
+	}
+	return nil
+}
+
+// MaintenanceWindow a time range no scheduled execution is started in. It either recurs at the start defined by a cron
+// expression or is an absolute time range.
+type MaintenanceWindow struct {
+	// Name the name of the window, used in logs, events and metrics
+	Name string `json:"name"`
+	// CronExpression the start of a recurring window, evaluated in the time zone of the controller
+	CronExpression string `json:"cronExpression,omitempty"`
+	// Duration the duration of a recurring window
+	Duration metav1.Duration `json:"duration,omitempty"`
+	// Start the start of an absolute window
+	Start *metav1.Time `json:"start,omitempty"`
+	// End the end of an absolute window
+	End *metav1.Time `json:"end,omitempty"`
+	// Action the action of an execution scheduled within the window. Default is MaintenanceActionSkip
+	Action string `json:"action,omitempty"`
+
+	spec cron.Schedule
+}
+
+// EndOf get the end of the window if the given time is within the window.
+func (w *MaintenanceWindow) EndOf(t time.Time) (time.Time, bool) {
+	if w.spec != nil {
+		// the first start after t-duration is the start of the window t is within, if it is not after t
+		start := w.spec.Next(t.Add(-w.Duration.Duration))
+		if !start.After(t) {
+			return start.Add(w.Duration.Duration), true
+		}
+		return time.Time{}, false
+	}
+	if w.Start != nil && w.End != nil && !t.Before(w.Start.Time) && t.Before(w.End.Time) {
+		return w.End.Time, true
+	}
+	return time.Time{}, false
+}
+
+func (w *MaintenanceWindow) validate() error {
+	if w.Name == "" {
+		return errors.New("maintenance window has no name")
+	}
+	if w.Action == "" {
+		w.Action = MaintenanceActionSkip
+	}
+	if w.Action != MaintenanceActionSkip && w.Action != MaintenanceActionDefer {
+		return fmt.Errorf("invalid action %q of maintenance window %q, must be one of %v",
+			w.Action, w.Name, []string{MaintenanceActionSkip, MaintenanceActionDefer})
+	}
+
+	if w.CronExpression != "" {
+		if w.Start != nil || w.End != nil {
+			return fmt.Errorf("maintenance window %q must either define a cron expression or a start and end", w.Name)
+		}
+		if w.Duration.Duration <= 0 {
+			return fmt.Errorf("maintenance window %q must have a positive duration", w.Name)
+		}
+		spec, err := CronParser.Parse(w.CronExpression)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression %q of maintenance window %q: %w", w.CronExpression, w.Name, err)
+		}
+		w.spec = spec
+		return nil
+	}
+
+	if w.Start == nil || w.End == nil {
+		return fmt.Errorf("maintenance window %q must either define a cron expression or a start and end", w.Name)
+	}
+	if !w.End.After(w.Start.Time) {
+		return fmt.Errorf("the end of maintenance window %q must be after its start", w.Name)
+	}
+	return nil
+}
+
 // Metric config.
 type Metric struct {
 	Help   string   `json:"help"`
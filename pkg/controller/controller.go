@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -26,8 +29,25 @@ const (
 	LabelOwner = "batch-job-controller.bakito.github.com/owner"
 	// LabelExecutionID execution id label.
 	LabelExecutionID = "batch-job-controller.bakito.github.com/execution-id"
+	// LabelAttempt attempt label of retried job pods.
+	LabelAttempt = "batch-job-controller.bakito.github.com/attempt"
+	// LabelSchedule schedule label of job pods of named schedules.
+	LabelSchedule = "batch-job-controller.bakito.github.com/schedule"
+	// AnnotationTarget target annotation of job pods, the identity of the target the job runs for.
+	AnnotationTarget = "batch-job-controller.bakito.github.com/target"
+
+	eventActionJobFailed = "JobFailed"
 )
 
+// waitingFailureReasons container waiting reasons of job pods that will not recover by themselves.
+var waitingFailureReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+}
+
 var clog = ctrl.Log.WithName("pod-controller")
 
 // PodReconciler reconciler.
@@ -35,6 +55,8 @@ type PodReconciler struct {
 	client.Client
 	coreClient corev1client.CoreV1Interface
 	Controller lifecycle.Controller
+	// EventRecorder records the failure reason of job pods that can not run to completion
+	EventRecorder events.EventRecorder
 }
 
 // SetupWithManager setup.
@@ -69,11 +91,22 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	executionID := pod.GetLabels()[LabelExecutionID]
-	node := pod.Spec.NodeName
+	node := PodTarget(pod)
+	attempt := PodAttempt(pod)
+
+	if attempt != r.Controller.Attempt(executionID, node) {
+		// the pod of a previous attempt of a retried job
+		return reconcile.Result{}, nil
+	}
 
 	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
-		if r.Controller.Config().SavePodLog && r.Controller.Has(node, executionID) {
-			r.savePodLogs(ctx, pod, executionID)
+		cfg := r.Controller.Config()
+		if cfg.IsJobMode() {
+			// the termination is reconciled with the batch job of the pod
+			return reconcile.Result{}, nil
+		}
+		if cfg.SavePodLog && r.Controller.Has(node, executionID) {
+			r.savePodLogs(ctx, pod, executionID, attempt)
 		}
 		if err := r.Controller.PodTerminated(executionID, node, pod.Status.Phase); err != nil {
 			if !errors.Is(err, &lifecycle.ExecutionIDNotFoundError{}) {
@@ -81,18 +114,64 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 				return reconcile.Result{}, err
 			}
 		}
+	} else if reason, message := failureReason(pod); reason != "" {
+		failed, err := r.Controller.PodFailed(executionID, node, reason)
+		if err != nil {
+			if !errors.Is(err, &lifecycle.ExecutionIDNotFoundError{}) {
+				podLog.Error(err, "unexpected error")
+				return reconcile.Result{}, err
+			}
+		}
+		if failed && r.EventRecorder != nil {
+			r.EventRecorder.Eventf(pod, nil, corev1.EventTypeWarning, reason, eventActionJobFailed, "%s", message)
+		}
 	}
 
 	return reconcile.Result{}, nil
 }
 
-func (r *PodReconciler) savePodLogs(ctx context.Context, pod *corev1.Pod, executionID string) {
+// failureReason returns the reason and message if the pod can not run to completion, an empty reason otherwise.
+func failureReason(pod *corev1.Pod) (string, string) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+			return c.Reason, c.Message
+		}
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		if cs.State.Waiting != nil && waitingFailureReasons[cs.State.Waiting.Reason] {
+			return cs.State.Waiting.Reason, fmt.Sprintf("container %s: %s", cs.Name, cs.State.Waiting.Message)
+		}
+	}
+	return "", ""
+}
+
+// PodAttempt get the attempt of a job pod, 0 if it is the first attempt.
+func PodAttempt(pod *corev1.Pod) int {
+	return attemptOf(pod)
+}
+
+func attemptOf(obj metav1.Object) int {
+	attempt, _ := strconv.Atoi(obj.GetLabels()[LabelAttempt])
+	return attempt
+}
+
+// PodTarget get the identity of the target of a job pod, the node of pods without target annotation.
+func PodTarget(pod *corev1.Pod) string {
+	if t := pod.GetAnnotations()[AnnotationTarget]; t != "" {
+		return t
+	}
+	return pod.Spec.NodeName
+}
+
+func (r *PodReconciler) savePodLogs(ctx context.Context, pod *corev1.Pod, executionID string, attempt int) {
+	node := PodTarget(pod)
 	for _, c := range pod.Spec.Containers {
-		clog := clog.WithValues("node", pod.Spec.NodeName, "id", executionID, "container", c.Name)
+		clog := clog.WithValues("node", node, "id", executionID, "container", c.Name)
 		if l, err := r.getPodLog(ctx, pod.Namespace, pod.Name, c.Name); err != nil {
 			clog.Error(err, "could not get log of container")
 		} else {
-			if err := r.savePodLog(pod.Spec.NodeName, executionID, c.Name, l); err != nil {
+			if err := r.savePodLog(node, executionID, c.Name, attempt, l); err != nil {
 				clog.Error(err, "error saving container log file")
 			} else {
 				clog.Info("saved container log file")
@@ -121,10 +200,15 @@ func (r *PodReconciler) getPodLog(ctx context.Context, namespace, name, containe
 	return str, nil
 }
 
-func (r *PodReconciler) savePodLog(node, executionID, name, data string) error {
+func (r *PodReconciler) savePodLog(node, executionID, name string, attempt int, data string) error {
 	if err := r.Controller.Config().MkReportDir(executionID); err != nil {
 		return err
 	}
-	fileName := r.Controller.Config().ReportFileName(executionID, fmt.Sprintf("%s-container-%s.log", node, name))
+	prefix := node
+	if attempt > 0 {
+		// keep the logs of all attempts
+		prefix = fmt.Sprintf("%s-attempt-%d", node, attempt)
+	}
+	fileName := r.Controller.Config().ReportFileName(executionID, fmt.Sprintf("%s-container-%s.log", prefix, name))
 	return os.WriteFile(fileName, []byte(data), 0o600)
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	gm "go.uber.org/mock/gomock"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +24,7 @@ import (
 
 	"github.com/bakito/batch-job-controller/pkg/config"
 	mockclient "github.com/bakito/batch-job-controller/pkg/mocks/client"
+	mockevents "github.com/bakito/batch-job-controller/pkg/mocks/events"
 	mocklifecycle "github.com/bakito/batch-job-controller/pkg/mocks/lifecycle"
 	mocklogr "github.com/bakito/batch-job-controller/pkg/mocks/logr"
 	"github.com/bakito/batch-job-controller/pkg/test"
@@ -97,6 +99,7 @@ var _ = Describe("Controller", func() {
 
 			r = &PodReconciler{}
 			r.Controller = mockController
+			mockController.EXPECT().Attempt(gm.Any(), gm.Any()).Return(0).AnyTimes()
 			r.Client = mockClient
 			r.coreClient = coreClient
 
@@ -172,6 +175,150 @@ var _ = Describe("Controller", func() {
 			Ω(result).ShouldNot(BeNil())
 			Ω(result.RequeueAfter).Should(Equal(time.Duration(0)))
 		})
+		It("should ignore pods of a previous attempt", func() {
+			mockController = mocklifecycle.NewMockController(mockCtrl)
+			r.Controller = mockController
+			mockController.EXPECT().Attempt(executionID, "node-a").Return(2)
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink)
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+				Do(func(_ context.Context, _ client.ObjectKey, pod *corev1.Pod, _ ...client.GetOption) error {
+					pod.ObjectMeta = metav1.ObjectMeta{Labels: map[string]string{
+						LabelExecutionID: executionID,
+						LabelAttempt:     "1",
+					}}
+					pod.Spec.NodeName = "node-a"
+					pod.Status = corev1.PodStatus{Phase: corev1.PodFailed}
+					return nil
+				})
+
+			result, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result.RequeueAfter).Should(Equal(time.Duration(0)))
+		})
+		It("should use the target annotation as identity of the pod", func() {
+			mockController = mocklifecycle.NewMockController(mockCtrl)
+			r.Controller = mockController
+			mockController.EXPECT().Attempt(executionID, "app.data").Return(0)
+			mockController.EXPECT().Config().Return(cfg).AnyTimes()
+			mockController.EXPECT().PodTerminated(executionID, "app.data", corev1.PodSucceeded)
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink)
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+				Do(func(_ context.Context, _ client.ObjectKey, pod *corev1.Pod, _ ...client.GetOption) error {
+					pod.ObjectMeta = metav1.ObjectMeta{
+						Labels:      map[string]string{LabelExecutionID: executionID},
+						Annotations: map[string]string{AnnotationTarget: "app.data"},
+					}
+					pod.Spec.NodeName = "node-a"
+					pod.Status = corev1.PodStatus{Phase: corev1.PodSucceeded}
+					return nil
+				})
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should save the logs of a retried pod with the attempt", func() {
+			cfg.SavePodLog = true
+			mockController = mocklifecycle.NewMockController(mockCtrl)
+			r.Controller = mockController
+			mockController.EXPECT().Attempt(executionID, "node-a").Return(1)
+			mockController.EXPECT().Config().Return(cfg).AnyTimes()
+			mockController.EXPECT().Has("node-a", executionID).Return(true)
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink).AnyTimes()
+			mockSink.EXPECT().Info(gm.Any(), gm.Any(), gm.Any(), gm.Any()).AnyTimes()
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+				Do(func(_ context.Context, _ client.ObjectKey, pod *corev1.Pod, _ ...client.GetOption) error {
+					pod.ObjectMeta = metav1.ObjectMeta{Labels: map[string]string{
+						LabelExecutionID: executionID,
+						LabelAttempt:     "1",
+					}}
+					pod.Spec = corev1.PodSpec{
+						NodeName:   "node-a",
+						Containers: []corev1.Container{{Name: "job"}},
+					}
+					pod.Status = corev1.PodStatus{Phase: corev1.PodFailed}
+					return nil
+				})
+			mockController.EXPECT().PodTerminated(executionID, "node-a", corev1.PodFailed)
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(filepath.Join(cfg.ReportDirectory, executionID, "node-a-attempt-1-container-job.log")).Should(BeAnExistingFile())
+		})
+		It("should report an image pull failure", func() {
+			mockRecorder := mockevents.NewMockEventRecorder(mockCtrl)
+			r.EventRecorder = mockRecorder
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink)
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+				Do(func(_ context.Context, _ client.ObjectKey, pod *corev1.Pod, _ ...client.GetOption) error {
+					pod.ObjectMeta = metav1.ObjectMeta{Labels: map[string]string{LabelExecutionID: executionID}}
+					pod.Spec.NodeName = "node-a"
+					pod.Status = corev1.PodStatus{
+						Phase: corev1.PodPending,
+						ContainerStatuses: []corev1.ContainerStatus{{
+							Name: "job",
+							State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+								Reason:  "ErrImagePull",
+								Message: "image not found",
+							}},
+						}},
+					}
+					return nil
+				})
+			mockController.EXPECT().PodFailed(executionID, "node-a", "ErrImagePull").Return(true, nil)
+			mockRecorder.EXPECT().Eventf(gm.Any(), nil, corev1.EventTypeWarning, "ErrImagePull", eventActionJobFailed,
+				"%s", "container job: image not found")
+
+			result, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result.RequeueAfter).Should(Equal(time.Duration(0)))
+		})
+		It("should report an unschedulable pod only once", func() {
+			mockRecorder := mockevents.NewMockEventRecorder(mockCtrl)
+			r.EventRecorder = mockRecorder
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink).Times(2)
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+				Do(func(_ context.Context, _ client.ObjectKey, pod *corev1.Pod, _ ...client.GetOption) error {
+					pod.ObjectMeta = metav1.ObjectMeta{Labels: map[string]string{LabelExecutionID: executionID}}
+					pod.Status = corev1.PodStatus{
+						Phase: corev1.PodPending,
+						Conditions: []corev1.PodCondition{{
+							Type:    corev1.PodScheduled,
+							Status:  corev1.ConditionFalse,
+							Reason:  corev1.PodReasonUnschedulable,
+							Message: "0/1 nodes are available",
+						}},
+					}
+					return nil
+				}).Times(2)
+			gm.InOrder(
+				mockController.EXPECT().PodFailed(executionID, gm.Any(), corev1.PodReasonUnschedulable).Return(true, nil),
+				mockController.EXPECT().PodFailed(executionID, gm.Any(), corev1.PodReasonUnschedulable).Return(false, nil),
+			)
+			mockRecorder.EXPECT().Eventf(gm.Any(), nil, corev1.EventTypeWarning, corev1.PodReasonUnschedulable,
+				eventActionJobFailed, "%s", "0/1 nodes are available")
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should not report a running pod", func() {
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink)
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{})).
+				Do(func(_ context.Context, _ client.ObjectKey, pod *corev1.Pod, _ ...client.GetOption) error {
+					pod.Status = corev1.PodStatus{
+						Phase: corev1.PodRunning,
+						ContainerStatuses: []corev1.ContainerStatus{{
+							Name:  "job",
+							State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+						}},
+					}
+					return nil
+				})
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
 		It("should return error on update controller error", func() {
 			mockController.EXPECT().Config().Return(cfg)
 			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink)
@@ -191,4 +338,141 @@ var _ = Describe("Controller", func() {
 			Ω(result.RequeueAfter).Should(Equal(time.Duration(0)))
 		})
 	})
+	Context("JobReconciler", func() {
+		var (
+			r              *JobReconciler
+			mockCtrl       *gm.Controller // gomock struct
+			mockController *mocklifecycle.MockController
+			mockClient     *mockclient.MockClient
+			mockSink       *mocklogr.MockLogSink
+			ctx            context.Context
+			cfg            config.Config
+			executionID    string
+			bj             batchv1.Job
+		)
+		BeforeEach(func() {
+			executionID = uuid.NewString()
+			mockCtrl = gm.NewController(GinkgoT())
+			mockController = mocklifecycle.NewMockController(mockCtrl)
+			mockClient = mockclient.NewMockClient(mockCtrl)
+			mockSink = mocklogr.NewMockLogSink(mockCtrl)
+
+			mockSink.EXPECT().Init(gm.Any())
+			mockSink.EXPECT().Enabled(gm.Any()).AnyTimes().Return(true)
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink).AnyTimes()
+			ctx = log.IntoContext(context.TODO(), logr.New(mockSink)) //nolint:fatcontext // need to assign the context here
+
+			cfg = config.Config{JobMode: config.JobModeJob}
+			bj = batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "job-a",
+					Namespace:   "ns",
+					Labels:      map[string]string{LabelExecutionID: executionID},
+					Annotations: map[string]string{AnnotationTarget: "node-a"},
+				},
+			}
+			bj.Spec.Template.Annotations = bj.Annotations
+
+			r = &JobReconciler{PodReconciler: &PodReconciler{}}
+			r.Controller = mockController
+			r.Client = mockClient
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&batchv1.Job{})).
+				DoAndReturn(func(_ context.Context, _ client.ObjectKey, obj *batchv1.Job, _ ...client.GetOption) error {
+					bj.DeepCopyInto(obj)
+					return nil
+				}).AnyTimes()
+		})
+		It("should update controller on job complete", func() {
+			bj.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			mockController.EXPECT().Attempt(executionID, "node-a").Return(0)
+			mockController.EXPECT().Config().Return(cfg)
+			mockController.EXPECT().PodTerminated(executionID, "node-a", corev1.PodSucceeded)
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should update controller on job failed", func() {
+			bj.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+			mockController.EXPECT().Attempt(executionID, "node-a").Return(0)
+			mockController.EXPECT().Config().Return(cfg)
+			mockController.EXPECT().PodTerminated(executionID, "node-a", corev1.PodFailed)
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should not update controller on an active job", func() {
+			bj.Status.Active = 1
+			mockController.EXPECT().Attempt(executionID, "node-a").Return(0)
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should ignore jobs of a previous attempt", func() {
+			bj.Labels[LabelAttempt] = "1"
+			bj.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+			mockController.EXPECT().Attempt(executionID, "node-a").Return(2)
+
+			_, err := r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should save the logs of the latest pod of the job", func() {
+			tmp, err := test.TempDir(executionID)
+			Ω(err).ShouldNot(HaveOccurred())
+			DeferCleanup(func() error {
+				return os.RemoveAll(tmp)
+			})
+			cfg.ReportDirectory = tmp
+			cfg.SavePodLog = true
+			r.coreClient = fake.NewClientset().CoreV1()
+			bj.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			mockSink.EXPECT().Info(gm.Any(), gm.Any(), gm.Any(), gm.Any()).AnyTimes()
+			mockController.EXPECT().Attempt(executionID, "node-a").Return(0)
+			mockController.EXPECT().Config().Return(cfg).AnyTimes()
+			mockController.EXPECT().Has("node-a", executionID).Return(true)
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.PodList{}), gm.Any(), gm.Any()).
+				DoAndReturn(func(_ context.Context, list *corev1.PodList, _ ...client.ListOption) error {
+					list.Items = []corev1.Pod{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:              "job-a-1",
+								Annotations:       bj.Spec.Template.Annotations,
+								CreationTimestamp: metav1.Unix(1, 0),
+							},
+							Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "old"}}},
+						},
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:              "job-a-2",
+								Annotations:       bj.Spec.Template.Annotations,
+								CreationTimestamp: metav1.Unix(2, 0),
+							},
+							Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "new"}}},
+						},
+					}
+					return nil
+				})
+			mockController.EXPECT().PodTerminated(executionID, "node-a", corev1.PodSucceeded)
+
+			_, err = r.Reconcile(ctx, ctrl.Request{})
+			Ω(err).ShouldNot(HaveOccurred())
+			files, err := os.ReadDir(filepath.Join(tmp, executionID))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(files).Should(HaveLen(1))
+			Ω(files[0].Name()).Should(ContainSubstring("new"))
+		})
+	})
+	Context("JobPhase", func() {
+		It("should map the job conditions to the pod phase", func() {
+			bj := &batchv1.Job{}
+			Ω(JobPhase(bj)).Should(Equal(corev1.PodPending))
+			bj.Status.Active = 1
+			Ω(JobPhase(bj)).Should(Equal(corev1.PodRunning))
+			bj.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionFalse}}
+			Ω(JobPhase(bj)).Should(Equal(corev1.PodRunning))
+			bj.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Ω(JobPhase(bj)).Should(Equal(corev1.PodSucceeded))
+			bj.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+			Ω(JobPhase(bj)).Should(Equal(corev1.PodFailed))
+		})
+	})
 })
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/bakito/batch-job-controller/pkg/lifecycle"
+)
+
+// JobReconciler reconciler of the batch/v1 jobs of the job mode Job. The pods of the jobs are reconciled by the
+// PodReconciler, which detects the pods that can not run to completion.
+type JobReconciler struct {
+	*PodReconciler
+}
+
+// SetupWithManager setup, the PodReconciler has to be set up before.
+func (r *JobReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("batch-job").
+		For(&batchv1.Job{}).
+		WithEventFilter(&podPredicate{}).
+		Complete(r)
+}
+
+// Reconcile reconcile batch jobs.
+func (r *JobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	jobLog := log.FromContext(ctx)
+	bj := &batchv1.Job{}
+	err := r.Get(ctx, req.NamespacedName, bj)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+
+		jobLog.Error(err, "unexpected error")
+		return reconcile.Result{}, err
+	}
+
+	executionID := bj.GetLabels()[LabelExecutionID]
+	node := JobTarget(bj)
+	attempt := attemptOf(bj)
+
+	if attempt != r.Controller.Attempt(executionID, node) {
+		// the job of a previous attempt of a retried job
+		return reconcile.Result{}, nil
+	}
+
+	phase := JobPhase(bj)
+	if phase != corev1.PodSucceeded && phase != corev1.PodFailed {
+		return reconcile.Result{}, nil
+	}
+	if r.Controller.Config().SavePodLog && r.Controller.Has(node, executionID) {
+		if pod, err := r.latestPod(ctx, bj); err != nil {
+			jobLog.Error(err, "could not get the pod of the job")
+		} else if pod != nil {
+			r.savePodLogs(ctx, pod, executionID, attempt)
+		}
+	}
+	if err := r.Controller.PodTerminated(executionID, node, phase); err != nil {
+		if !errors.Is(err, &lifecycle.ExecutionIDNotFoundError{}) {
+			jobLog.Error(err, "unexpected error")
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// latestPod get the latest created pod of the job, nil if it has none.
+func (r *JobReconciler) latestPod(ctx context.Context, bj *batchv1.Job) (*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	err := r.List(ctx, podList, client.InNamespace(bj.Namespace), client.MatchingLabels{batchv1.JobNameLabel: bj.Name})
+	if err != nil || len(podList.Items) == 0 {
+		return nil, err
+	}
+	pod := slices.MaxFunc(podList.Items, func(a, b corev1.Pod) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	})
+	return &pod, nil
+}
+
+// JobPhase map the conditions of a batch job to the phase of its job pod.
+func JobPhase(bj *batchv1.Job) corev1.PodPhase {
+	for _, c := range bj.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return corev1.PodSucceeded
+		case batchv1.JobFailed:
+			return corev1.PodFailed
+		}
+	}
+	if bj.Status.Active > 0 {
+		return corev1.PodRunning
+	}
+	return corev1.PodPending
+}
+
+// JobTarget get the identity of the target of a batch job.
+func JobTarget(bj *batchv1.Job) string {
+	return bj.GetAnnotations()[AnnotationTarget]
+}
@@ -0,0 +1,116 @@
+package cron
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// lastStartsFileName name of the file the last starts of the schedules are recorded to, it is hidden to never collide
+// with an execution id.
+const lastStartsFileName = ".schedules.json"
+
+// catchUp start the scheduled executions that were missed while the controller was down or not the leader.
+// Schedules without a recorded start get the current time as baseline to detect missed executions from now on.
+func (j *cronJob) catchUp() {
+	j.startsMux.Lock()
+	defer j.startsMux.Unlock()
+
+	starts, err := j.loadStarts()
+	if err != nil {
+		log.Error(err, "could not read the last starts of the schedules")
+		return
+	}
+
+	now := time.Now().In(j.location)
+	var baseline bool
+	for _, sc := range j.cfg.AllSchedules() {
+		last, ok := starts[sc.Name]
+		if !ok {
+			starts[sc.Name] = now
+			baseline = true
+			continue
+		}
+		if sc.StartingDeadline.Duration <= 0 {
+			continue
+		}
+		s := j.schedules[sc.Name]
+		if missed, ok := missedRun(s.spec, last, now, sc.StartingDeadline.Duration); ok {
+			log.WithValues(
+				"schedule", sc.Name,
+				"missed", missed.Format(time.RFC3339),
+				"lastStart", last.Format(time.RFC3339),
+			).Info("starting missed execution")
+			go j.startPods(sc.Name)
+		}
+	}
+
+	if baseline {
+		if err := j.saveStarts(starts); err != nil {
+			log.Error(err, "could not record the last starts of the schedules")
+		}
+	}
+}
+
+// recordStart record the start of a scheduled execution of the schedule. Executions skipped within a maintenance window
+// or rejected by the concurrency policy are recorded as well, so they are not caught up.
+func (j *cronJob) recordStart(name string, t time.Time) {
+	j.startsMux.Lock()
+	defer j.startsMux.Unlock()
+
+	starts, err := j.loadStarts()
+	if err != nil {
+		log.WithValues("schedule", name).Error(err, "could not read the last starts of the schedules")
+		starts = make(map[string]time.Time)
+	}
+	starts[name] = t
+	if err := j.saveStarts(starts); err != nil {
+		log.WithValues("schedule", name).Error(err, "could not record the last start of the schedule")
+	}
+}
+
+// missedRun get the latest scheduled time since the last start that is due and not older than the deadline.
+func missedRun(spec cron.Schedule, last, now time.Time, deadline time.Duration) (time.Time, bool) {
+	from := now.Add(-deadline)
+	if last.After(from) {
+		from = last
+	}
+	var missed time.Time
+	for t := spec.Next(from); !t.IsZero() && !t.After(now); t = spec.Next(t) {
+		missed = t
+	}
+	return missed, !missed.IsZero()
+}
+
+func (j *cronJob) loadStarts() (map[string]time.Time, error) {
+	starts := make(map[string]time.Time)
+	b, err := os.ReadFile(filepath.Join(j.cfg.ReportDirectory, lastStartsFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return starts, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &starts); err != nil {
+		return nil, err
+	}
+	return starts, nil
+}
+
+func (j *cronJob) saveStarts(starts map[string]time.Time) error {
+	b, err := json.Marshal(starts)
+	if err != nil {
+		return err
+	}
+	fileName := filepath.Join(j.cfg.ReportDirectory, lastStartsFileName)
+	// write to a temp file first to never leave a partially written file behind
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
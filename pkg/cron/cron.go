@@ -2,23 +2,51 @@ package cron
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/bakito/batch-job-controller/pkg/config"
+	"github.com/bakito/batch-job-controller/pkg/controller"
 	"github.com/bakito/batch-job-controller/pkg/job"
 	"github.com/bakito/batch-job-controller/pkg/lifecycle"
+	"github.com/bakito/batch-job-controller/pkg/metrics"
+	"github.com/bakito/batch-job-controller/pkg/target"
 )
 
-var log = ctrl.Log.WithName("cron")
+const (
+	eventReasonSkipped     = "ExecutionSkipped"
+	eventReasonDeferred    = "ExecutionDeferred"
+	eventReasonCanary      = "CanaryFailed"
+	eventActionMaintenance = "MaintenanceWindow"
+	eventActionCanary      = "Canary"
+)
+
+var (
+	log = ctrl.Log.WithName("cron")
+	// retrySuffix the name suffix of the pod of a retried job
+	retrySuffix = regexp.MustCompile(`-retry-\d+$`)
+)
 
 // Job creates a new Job runner instance.
 func Job(extender ...job.CustomPodEnv) manager.Runnable {
@@ -30,14 +58,137 @@ func Job(extender ...job.CustomPodEnv) manager.Runnable {
 type cronJob struct {
 	client     client.Client
 	controller lifecycle.Controller
-	running    bool
 	cfg        *config.Config
 	extender   []job.CustomPodEnv
+	prom       *metrics.Collector
+	recorder   events.EventRecorder
+	// location the time zone the cron expressions are evaluated in
+	location *time.Location
+	// schedules the state of the schedules by name, the map is not modified after the config is injected
+	schedules map[string]*schedule
+	// startsMux guards the file the last starts of the schedules are recorded to
+	startsMux sync.Mutex
+	// cooldowns the time a job was last started by the node watcher by schedule and node, guarded by cooldownMux
+	cooldowns   map[string]time.Time
+	cooldownMux sync.Mutex
+}
+
+// schedule the state of a schedule.
+type schedule struct {
+	name string
+	spec cron.Schedule
+	// deferred is true while an execution waits for the end of a maintenance window
+	deferred atomic.Bool
+
+	mux  sync.Mutex
+	cond *sync.Cond
+	// running is true from preparing an execution until all of its pods are terminated, guarded by mux
+	running bool
+	// generation the generation of the latest started execution, a replaced execution must not release the schedule
+	generation uint64
+	// executionID the id of the latest started execution, empty while it is prepared, guarded by mux
+	executionID string
+	// queued is true while a scheduled execution waits for the running one, guarded by mux
+	queued bool
+	// pending the ready nodes to be added to the running execution, guarded by mux
+	pending []target.Target
+	// dispatched the run of the latest execution once all of its jobs are dispatched, ready nodes are added to it
+	// directly until it is finished, guarded by mux
+	dispatched *run
+	// nodeRun the run of the latest execution of ready nodes once its jobs are dispatched, further ready nodes are
+	// added to it until it is finished. It does not hold the schedule, guarded by mux
+	nodeRun *run
+}
+
+func newSchedule(name string) *schedule {
+	s := &schedule{name: name}
+	s.cond = sync.NewCond(&s.mux)
+	return s
+}
+
+// acquire the schedule for a new execution according to the concurrency policy.
+// Only scheduled executions are queued, on demand executions are rejected instead.
+func (j *cronJob) acquire(s *schedule, policy string, queue bool) (uint64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.running {
+		switch {
+		case policy == config.ConcurrencyPolicyReplace && s.executionID != "":
+			log.WithValues("schedule", s.name, "id", s.executionID).Info("replacing running execution")
+			if err := j.controller.Cancel(s.executionID, "replaced by a new execution"); err != nil {
+				log.WithValues("schedule", s.name, "id", s.executionID).Error(err, "could not cancel running execution")
+			}
+		case policy == config.ConcurrencyPolicyQueue && queue:
+			if s.queued {
+				log.WithValues("schedule", s.name).Info("an execution is already queued")
+				return 0, lifecycle.ErrExecutionRunning
+			}
+			log.WithValues("schedule", s.name).Info("queueing execution")
+			s.queued = true
+			for s.running {
+				s.cond.Wait()
+			}
+			s.queued = false
+		default:
+			log.WithValues("schedule", s.name).Info("last cronjob still running")
+			return 0, lifecycle.ErrExecutionRunning
+		}
+	}
+
+	s.running = true
+	s.generation++
+	s.executionID = ""
+	s.dispatched = nil
+	return s.generation, nil
+}
+
+// started record the id of the execution of the given generation.
+func (s *schedule) started(generation uint64, executionID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.generation == generation {
+		s.executionID = executionID
+	}
+}
+
+// drain take the nodes that became ready while the execution of the run was dispatched.
+// If no node is pending, the run is marked as dispatched.
+func (s *schedule) drain(r *run) []target.Target {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.generation != r.generation {
+		return nil
+	}
+	if len(s.pending) == 0 {
+		s.dispatched = r
+		return nil
+	}
+	nodes := s.pending
+	s.pending = nil
+	return nodes
+}
+
+// release the schedule after the execution of the given generation is finished or could not be started.
+func (s *schedule) release(generation uint64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.generation == generation {
+		s.running = false
+		s.executionID = ""
+		s.dispatched = nil
+	}
+	s.cond.Broadcast()
 }
 
 // InjectConfig inject the config.
 func (j *cronJob) InjectConfig(cfg *config.Config) {
 	j.cfg = cfg
+	j.cooldowns = make(map[string]time.Time)
+	j.schedules = make(map[string]*schedule)
+	for _, s := range cfg.AllSchedules() {
+		j.schedules[s.Name] = newSchedule(s.Name)
+	}
 }
 
 // InjectController inject the controller.
@@ -45,31 +196,66 @@ func (j *cronJob) InjectController(c lifecycle.Controller) {
 	j.controller = c
 }
 
+// InjectMetrics inject the metrics collector.
+func (j *cronJob) InjectMetrics(m *metrics.Collector) {
+	j.prom = m
+}
+
+// InjectEventRecorder inject the event recorder.
+func (j *cronJob) InjectEventRecorder(er events.EventRecorder) {
+	j.recorder = er
+}
+
 // InjectClient inject the client.
 func (j *cronJob) InjectClient(c client.Client) {
 	j.client = c
 }
 
+// verify interface is implemented.
+var _ lifecycle.Trigger = &cronJob{}
+
 // NeedLeaderElection may only start if leader is elected.
 func (*cronJob) NeedLeaderElection() bool {
 	return true
 }
 
 // Start implement manager.Runnable.
-func (j *cronJob) Start(_ context.Context) error {
-	log.WithValues("expression", j.cfg.CronExpression).Info("starting cron")
-	c := cron.New()
-	_, err := c.AddFunc(j.cfg.CronExpression, j.startPods)
+func (j *cronJob) Start(ctx context.Context) error {
+	if err := j.restore(ctx); err != nil {
+		log.Error(err, "error restoring executions")
+	}
+
+	loc, err := j.cfg.Location()
 	if err != nil {
 		return err
 	}
+	j.location = loc
+
+	c := cron.New(cron.WithLocation(loc))
+	schedules := j.cfg.AllSchedules()
+	for _, s := range schedules {
+		log.WithValues("schedule", s.Name, "expression", s.CronExpression, "timeZone", loc.String()).Info("starting cron")
+		spec, err := config.CronParser.Parse(s.CronExpression)
+		if err != nil {
+			return err
+		}
+		sched := j.schedules[s.Name]
+		sched.spec = spec
+		c.Schedule(spec, cron.FuncJob(func() { j.tick(sched) }))
+		j.nextRun(sched)
+	}
+
+	j.catchUp()
 
 	if j.cfg.RunOnStartup {
+		log.Info("runOnStartup is deprecated, use startingDeadline to start missed executions instead")
 		go func() {
 			log.WithValues("delay", j.cfg.StartupDelay).Info("starting on startup")
 			time.Sleep(j.cfg.StartupDelay)
 			log.Info("starting")
-			j.startPods()
+			for _, s := range schedules {
+				j.startPods(s.Name)
+			}
 		}()
 	}
 
@@ -77,95 +263,587 @@ func (j *cronJob) Start(_ context.Context) error {
 	return nil
 }
 
-func (j *cronJob) deleteAll(obj client.Object) error {
+// restore the executions of the job pods that survived a restart of the controller.
+func (j *cronJob) restore(ctx context.Context) error {
+	if j.cfg.IsJobMode() {
+		return j.restoreBatch(ctx)
+	}
+	podList := &corev1.PodList{}
+	err := j.client.List(ctx, podList, client.InNamespace(j.cfg.Namespace), job.MatchingLabels(j.cfg.Name))
+	if err != nil {
+		return err
+	}
+
+	executions := make(map[string][]lifecycle.RestoredPod)
+	for _, p := range podList.Items {
+		if id := p.Labels[controller.LabelExecutionID]; id != "" {
+			executions[id] = append(executions[id], lifecycle.RestoredPod{
+				Job: &podJob{
+					id:       id,
+					nodeName: controller.PodTarget(&p),
+					client:   j.client,
+					pod:      p.DeepCopy(),
+				},
+				Pod:     p,
+				Attempt: controller.PodAttempt(&p),
+			})
+		}
+	}
+
+	// restore in order, so the latest execution is the current one
+	for _, id := range slices.Sorted(maps.Keys(executions)) {
+		log.WithValues("id", id, "pods", len(executions[id])).Info("restoring execution")
+		if err := j.controller.Restore(id, executions[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreBatch restore the executions of the batch jobs that survived a restart of the controller.
+// The phase of the pods of the restored executions is mapped from the conditions of the jobs.
+func (j *cronJob) restoreBatch(ctx context.Context) error {
+	jobList := &batchv1.JobList{}
+	err := j.client.List(ctx, jobList, client.InNamespace(j.cfg.Namespace), job.MatchingLabels(j.cfg.Name))
+	if err != nil {
+		return err
+	}
+
+	executions := make(map[string][]lifecycle.RestoredPod)
+	for _, bj := range jobList.Items {
+		if id := bj.Labels[controller.LabelExecutionID]; id != "" {
+			pod := corev1.Pod{
+				ObjectMeta: bj.ObjectMeta,
+				Status:     corev1.PodStatus{Phase: controller.JobPhase(&bj)},
+			}
+			executions[id] = append(executions[id], lifecycle.RestoredPod{
+				Job: &podJob{
+					id:       id,
+					nodeName: controller.JobTarget(&bj),
+					client:   j.client,
+					batch:    bj.DeepCopy(),
+				},
+				Pod:     pod,
+				Attempt: controller.PodAttempt(&pod),
+			})
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(executions)) {
+		log.WithValues("id", id, "jobs", len(executions[id])).Info("restoring execution")
+		if err := j.controller.Restore(id, executions[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobObject get an object of the kind the jobs run as.
+func (j *cronJob) jobObject() client.Object {
+	if j.cfg.IsJobMode() {
+		return &batchv1.Job{}
+	}
+	return &corev1.Pod{}
+}
+
+// deleteAll delete all objects of the controller, limited to the schedule if it is a named schedule.
+func (j *cronJob) deleteAll(obj client.Object, scheduleName string) error {
+	labels := job.MatchingLabels(j.cfg.Name)
+	if scheduleName != "" {
+		labels[controller.LabelSchedule] = scheduleName
+	}
 	return j.client.DeleteAllOf(
 		context.TODO(),
 		obj,
 		client.InNamespace(j.cfg.Namespace),
-		job.MatchingLabels(j.cfg.Name),
+		labels,
 		client.PropagationPolicy(metav1.DeletePropagationBackground),
 	) // set propagation policy to also delete assigned pods
 }
 
-func (j *cronJob) startPods() {
-	if j.running {
-		log.Info("last cronjob still running")
+// Trigger start a new execution on demand. The job pods are dispatched in the background.
+func (j *cronJob) Trigger(opts lifecycle.TriggerOptions) (string, error) {
+	name := opts.Schedule
+	if name == "" && opts.RerunOf != "" {
+		// a rerun belongs to the schedule of the original execution
+		name = j.cfg.ScheduleOf(opts.RerunOf)
+	}
+	if name == "" {
+		name = j.cfg.AllSchedules()[0].Name
+	}
+	s, ok := j.schedules[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", lifecycle.ErrUnknownSchedule, name)
+	}
+
+	r, err := j.prepare(s, opts, false)
+	if err != nil {
+		return "", err
+	}
+	go j.dispatch(s, r)
+	return r.id, nil
+}
+
+// tick start a scheduled execution of the schedule, delayed by a random jitter if configured.
+func (j *cronJob) tick(s *schedule) {
+	j.nextRun(s)
+	if jitter := j.cfg.Jitter.Duration; jitter > 0 {
+		delay := rand.N(jitter) // #nosec G404 no need for a secure random delay
+		log.WithValues("schedule", s.name, "delay", delay.String()).Info("delaying scheduled execution")
+		time.Sleep(delay)
+	}
+	j.startPods(s.name)
+}
+
+// nextRun record the time of the next scheduled execution of the schedule.
+func (j *cronJob) nextRun(s *schedule) {
+	next := s.spec.Next(time.Now().In(j.location))
+	j.prom.NextExecution(s.name, next)
+	log.WithValues("schedule", s.name, "next", next.Format(time.RFC3339)).Info("next run")
+}
+
+func (j *cronJob) startPods(scheduleName string) {
+	s, ok := j.schedules[scheduleName]
+	if !ok {
+		return
+	}
+	if !j.awaitMaintenance(s) {
+		// a skipped execution must not be caught up after a restart
+		j.recordStart(s.name, time.Now())
 		return
 	}
-	j.running = true
+	r, err := j.prepare(s, lifecycle.TriggerOptions{}, true)
+	if err != nil {
+		if errors.Is(err, lifecycle.ErrExecutionRunning) {
+			// rejected by the concurrency policy, it must not be caught up either
+			j.recordStart(s.name, time.Now())
+		}
+		return
+	}
+	j.recordStart(s.name, time.Now())
+	j.dispatch(s, r)
+}
+
+// awaitMaintenance check the maintenance windows before a scheduled execution is started. It returns false if the
+// execution is skipped, a deferred execution waits until the window ends.
+func (j *cronJob) awaitMaintenance(s *schedule) bool {
+	if len(j.cfg.MaintenanceWindows) == 0 {
+		return true
+	}
+	deferred := false
 	defer func() {
-		j.running = false
+		if deferred {
+			s.deferred.Store(false)
+		}
 	}()
+	for {
+		w, end, ok := j.cfg.MaintenanceWindowAt(time.Now().In(j.location))
+		if !ok {
+			return true
+		}
+		l := log.WithValues("schedule", s.name, "window", w.Name, "end", end.Format(time.RFC3339))
+		j.prom.MaintenanceBlocked(s.name, w.Name, w.Action)
+
+		if w.Action != config.MaintenanceActionDefer {
+			l.Info("skipping execution within maintenance window")
+			j.event(corev1.EventTypeWarning, eventReasonSkipped, eventActionMaintenance,
+				"scheduled execution skipped within maintenance window %q ending at %s", w.Name, end.Format(time.RFC3339))
+			return false
+		}
+		if !deferred {
+			if !s.deferred.CompareAndSwap(false, true) {
+				l.Info("execution is already deferred")
+				return false
+			}
+			deferred = true
+		}
+		l.Info("deferring execution until the end of the maintenance window")
+		j.event(corev1.EventTypeNormal, eventReasonDeferred, eventActionMaintenance,
+			"scheduled execution deferred until the end of maintenance window %q at %s", w.Name, end.Format(time.RFC3339))
+		time.Sleep(time.Until(end))
+	}
+}
+
+// event record an event of the controller, if its owner is known.
+func (j *cronJob) event(eventType, reason, action, note string, args ...any) {
+	if j.recorder != nil && j.cfg.Owner != nil {
+		j.recorder.Eventf(j.cfg.Owner, nil, eventType, reason, action, note, args...)
+	}
+}
 
-	// Fetch the ReplicaSet from the controller
-	nodeList := &corev1.NodeList{}
-	err := j.client.List(context.TODO(), nodeList, client.MatchingLabels(j.cfg.JobNodeSelector))
+// run an execution ready to dispatch its job pods.
+type run struct {
+	id              string
+	generation      uint64
+	schedule        config.Schedule
+	targets         []target.Target
+	callbackAddress string
+	log             logr.Logger
+	// dispatched the ids of the targets whose jobs were added to the execution, guarded by the mux of the schedule
+	// once the run is dispatched
+	dispatched map[string]bool
+}
+
+// prepare a new execution of the schedule with all targets matching the options.
+// If queue is true, the execution waits for the running one if the concurrency policy is Queue.
+// A rerun is a partial execution, the original execution and the job pods of the other targets are kept.
+func (j *cronJob) prepare(s *schedule, opts lifecycle.TriggerOptions, queue bool) (*run, error) {
+	sc, ok := j.cfg.ScheduleFor(s.name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", lifecycle.ErrUnknownSchedule, s.name)
+	}
+
+	generation, err := j.acquire(s, sc.ConcurrencyPolicy, queue)
 	if err != nil {
-		log.Error(err, "error listing nodes")
-		return
+		return nil, err
+	}
+	r, err := j.prepareRun(sc, opts, opts.RerunOf != "")
+	if err != nil {
+		s.release(generation)
+		return nil, err
+	}
+	r.generation = generation
+	s.started(generation, r.id)
+	return r, nil
+}
+
+// prepareRun prepare an execution of the schedule with all targets matching the options.
+// The job pods of previous executions of the schedule are deleted, unless the execution is partial.
+func (j *cronJob) prepareRun(sc config.Schedule, opts lifecycle.TriggerOptions, partial bool) (*run, error) {
+	source, err := j.targetSource(sc)
+	if err != nil {
+		log.Error(err, "invalid target source")
+		return nil, err
+	}
+	targets, err := source.Targets(context.TODO())
+	if err != nil {
+		log.Error(err, "error listing targets")
+		return nil, err
+	}
+
+	targets, err = filterTargets(targets, opts)
+	if err != nil {
+		log.Error(err, "error filtering targets")
+		return nil, err
 	}
-	var nodes []corev1.Node
-	for _, n := range nodeList.Items {
-		if isUsable(n, j.cfg.RunOnUnscheduledNodes) {
-			nodes = append(nodes, n)
+
+	// the steps that may fail precede the new execution, an announced execution is always dispatched
+	callbackAddress, err := j.callbackAddress()
+	if err != nil {
+		return nil, err
+	}
+	if !partial {
+		j.cancelOld(sc.Name)
+		l := log.WithValues("schedule", sc.Name)
+		l.Info("deleting old job pods")
+		if err := j.deleteAll(j.jobObject(), sc.Name); err != nil {
+			l.Error(err, "unable to delete old pods")
+			return nil, err
 		}
 	}
 
-	executionID := j.controller.NewExecution(len(nodes))
+	var executionID string
+	if partial {
+		executionID = j.controller.NewPartialExecution(sc.Name, len(targets))
+	} else {
+		executionID = j.controller.NewExecution(sc.Name, len(targets))
+	}
 
 	jobLog := log.WithValues("id", executionID)
 
-	jobLog.Info("deleting old job pods")
-	err = j.deleteAll(&corev1.Pod{})
+	if opts.RerunOf != "" {
+		if err := j.controller.LinkRerun(opts.RerunOf, executionID); err != nil {
+			jobLog.WithValues("rerunOf", opts.RerunOf).Error(err, "could not link rerun")
+		}
+	}
+
+	return &run{
+		id:              executionID,
+		schedule:        sc,
+		targets:         targets,
+		callbackAddress: callbackAddress,
+		log:             jobLog,
+		dispatched:      make(map[string]bool),
+	}, nil
+}
+
+// callbackAddress get the address the job pods report to, the pod IP of the controller or the IP of the callback
+// service.
+func (j *cronJob) callbackAddress() (string, error) {
+	if ip, ok := os.LookupEnv(config.EnvPodIP); ok {
+		return ip, nil
+	}
+	svc := &corev1.Service{}
+	err := j.client.Get(context.TODO(), client.ObjectKey{Namespace: j.cfg.Namespace, Name: j.cfg.CallbackServiceName}, svc)
+	if err != nil {
+		log.WithValues("service-name", j.cfg.CallbackServiceName).Error(err, "error getting service")
+		return "", err
+	}
+	return svc.Spec.ClusterIP, nil
+}
+
+// cancelOld cancel the unfinished executions of the schedule before their job pods are deleted.
+func (j *cronJob) cancelOld(scheduleName string) {
+	for _, es := range j.controller.Executions() {
+		if es.Schedule != scheduleName || es.Finished != nil {
+			continue
+		}
+		if err := j.controller.Cancel(es.ID, "replaced by a new execution"); err != nil {
+			log.WithValues("schedule", scheduleName, "id", es.ID).Error(err, "could not cancel previous execution")
+		}
+	}
+}
+
+// dispatch the job pods of an execution. Nodes that become ready meanwhile are added to the execution.
+// The schedule is released when all pods of the execution are terminated.
+func (j *cronJob) dispatch(s *schedule, r *run) {
+	r.log.Info("executing job")
+	if !j.dispatchTargets(s, r) {
+		j.abort(r)
+		s.release(r.generation)
+		return
+	}
+
+	_ = j.controller.AllAdded(r.id)
+
+	done, err := j.controller.Finished(r.id)
 	if err != nil {
-		jobLog.Error(err, "unable to delete old pods")
+		r.log.Error(err, "could not await execution")
+		s.release(r.generation)
 		return
 	}
+	go func() {
+		<-done
+		s.release(r.generation)
+	}()
+}
 
-	var callbackAddress string
-	if ip, ok := os.LookupEnv(config.EnvPodIP); ok {
-		callbackAddress = ip
-	} else {
-		// get service
-		svc := &corev1.Service{}
-		err = j.client.Get(context.TODO(), client.ObjectKey{Namespace: j.cfg.Namespace, Name: j.cfg.CallbackServiceName}, svc)
-		if err != nil {
-			jobLog.WithValues("service-name", j.cfg.CallbackServiceName).Error(err, "error getting service")
-			return
+// dispatchTargets add the jobs of the targets of the run to the execution, with a canary phase and in waves if
+// configured. Returns false if a pod could not be created from the template.
+func (j *cronJob) dispatchTargets(s *schedule, r *run) bool {
+	targets := r.targets
+	if r.schedule.Canary.Enabled() {
+		var ok bool
+		if targets, ok = j.canary(r); !ok {
+			return false
 		}
-		callbackAddress = svc.Spec.ClusterIP
 	}
+	if r.schedule.Rollout.Enabled() {
+		if !j.rollOut(r, targets) {
+			return false
+		}
+		targets = nil
+	}
+	for {
+		if !j.addPods(r, targets) {
+			return false
+		}
+		if targets = s.drain(r); len(targets) == 0 {
+			return true
+		}
+	}
+}
 
-	jobLog.Info("executing job")
-	for _, n := range nodes {
-		pod, err := job.New(j.cfg, n.Name, executionID, callbackAddress, j.cfg.Owner, j.extender...)
+// abort cancel the execution of the run, since the pod of a target could not be created from the template. The
+// execution is finished, its jobs already added are recorded as cancelled unless they are terminated.
+func (j *cronJob) abort(r *run) {
+	if err := j.controller.Cancel(r.id, "a pod could not be created from the template"); err != nil {
+		r.log.Error(err, "could not cancel execution")
+	}
+	_ = j.controller.AllAdded(r.id)
+}
+
+// addPods add the job pods of the targets not dispatched yet to the execution.
+// Returns false if a pod could not be created from the template.
+func (j *cronJob) addPods(r *run, targets []target.Target) bool {
+	for _, t := range targets {
+		if r.dispatched[t.ID()] {
+			continue
+		}
+		r.dispatched[t.ID()] = true
+		pj, err := j.newPodJob(r, t, 0)
 		if err != nil {
-			jobLog.Error(err, "error creating pod from template")
-			return
+			r.log.Error(err, "error creating pod from template")
+			return false
 		}
+		_ = j.controller.AddPod(pj)
+	}
+	return true
+}
 
-		_ = j.controller.AddPod(&podJob{
-			id:       executionID,
-			nodeName: n.Name,
-			log:      jobLog,
-			client:   j.client,
-			pod:      pod,
-		})
+// newPodJob create the job of the given attempt of a target of the run from the pod template.
+func (j *cronJob) newPodJob(r *run, t target.Target, attempt int) (*podJob, error) {
+	pod, err := job.New(j.cfg, r.schedule, t, r.id, attempt, r.callbackAddress, j.cfg.Owner, j.extender...)
+	if err != nil {
+		return nil, err
 	}
 
-	_ = j.controller.AllAdded(executionID)
+	pj := &podJob{
+		id:       r.id,
+		nodeName: t.ID(),
+		log:      r.log,
+		client:   j.client,
+		pod:      pod,
+		create: func(attempt int) (*podJob, error) {
+			return j.newPodJob(r, t, attempt)
+		},
+	}
+	if j.cfg.IsJobMode() {
+		pj.pod = nil
+		pj.batch = job.Batch(j.cfg, pod)
+	}
+	return pj, nil
 }
 
-func isUsable(node corev1.Node, runOnUnscheduledNodes bool) bool {
-	if !runOnUnscheduledNodes && node.Spec.Unschedulable {
-		return false
+// rollOut dispatch the targets of the execution in waves, each wave is awaited before the next one is started.
+// If the failed targets of a wave exceed the max failed percentage or the wave can not be awaited, the execution is
+// cancelled and the targets of the remaining waves are added as cancelled. Returns false if a pod could not be created
+// from the template.
+func (j *cronJob) rollOut(r *run, targets []target.Target) bool {
+	ro := r.schedule.Rollout
+	waves := waves(targets, ro.TopologyKey, ro.MaxInFlight)
+	for i, wave := range waves {
+		l := r.log.WithValues("wave", i+1, "waves", len(waves))
+		l.WithValues("nodes", len(wave)).Info("starting wave")
+		if !j.addPods(r, wave) {
+			return false
+		}
+		failed, err := j.controller.AwaitNodes(r.id, target.IDs(wave))
+		switch {
+		case errors.Is(err, lifecycle.ErrExecutionCancelled):
+			l.Info("execution cancelled, stopping rollout")
+			return j.addPods(r, slices.Concat(waves[i+1:]...))
+		case err != nil:
+			l.Error(err, "could not await wave, halting rollout")
+			return j.haltRollout(r, fmt.Sprintf("rollout halted: wave %d could not be awaited", i+1), waves[i+1:])
+		case i < len(waves)-1 && ro.Exceeded(failed, len(wave)):
+			l.WithValues("failed", failed).Info("halting rollout")
+			reason := fmt.Sprintf("rollout halted: %d of %d nodes of wave %d failed", failed, len(wave), i+1)
+			return j.haltRollout(r, reason, waves[i+1:])
+		}
+	}
+	return true
+}
+
+// haltRollout cancel the execution and add the targets of the remaining waves as cancelled.
+// Returns false if a pod could not be created from the template.
+func (j *cronJob) haltRollout(r *run, reason string, remaining [][]target.Target) bool {
+	if err := j.controller.Cancel(r.id, reason); err != nil {
+		r.log.Error(err, "could not cancel execution")
+	}
+	return j.addPods(r, slices.Concat(remaining...))
+}
+
+// canary dispatch the jobs of the canary targets of the execution and wait until they are terminated.
+// Returns the other targets to be dispatched if all canaries succeeded. If a canary failed, the execution is aborted
+// and the other targets are added as cancelled. Returns false if a pod could not be created from the template.
+func (j *cronJob) canary(r *run) ([]target.Target, bool) {
+	canaries, others := r.schedule.Canary.Split(r.targets)
+	if len(canaries) == 0 || len(others) == 0 {
+		// no canary phase if no target or every target is a canary
+		return r.targets, true
+	}
+	r.log.WithValues("canaries", len(canaries)).Info("starting canary phase")
+	if !j.addPods(r, canaries) {
+		return nil, false
 	}
-	for _, c := range node.Status.Conditions {
-		if c.Type == corev1.NodeReady {
-			return c.Status == corev1.ConditionTrue
+	failed, err := j.controller.AwaitNodes(r.id, target.IDs(canaries))
+	switch {
+	case errors.Is(err, lifecycle.ErrExecutionCancelled):
+		// the canaries did not fail, the other targets are added as cancelled
+		r.log.Info("execution cancelled during canary phase")
+		return nil, j.addPods(r, others)
+	case err != nil:
+		r.log.Error(err, "could not await canaries")
+		return others, true
+	case failed == 0:
+		r.log.Info("canary phase succeeded")
+		return others, true
+	}
+
+	r.log.WithValues("failed", failed, "canaries", len(canaries)).Info("canary phase failed, aborting execution")
+	j.prom.CanaryFailed(r.schedule.Name)
+	j.event(corev1.EventTypeWarning, eventReasonCanary, eventActionCanary,
+		"execution %s aborted: %d of %d canary nodes failed", r.id, failed, len(canaries))
+	reason := fmt.Sprintf("canary failed: %d of %d nodes failed", failed, len(canaries))
+	if err := j.controller.Cancel(r.id, reason); err != nil {
+		r.log.Error(err, "could not cancel execution")
+	}
+	return nil, j.addPods(r, others)
+}
+
+// waves split the targets into waves of up to maxInFlight targets of each group of targets with the same value of the
+// topology label. The groups are ordered by their value, targets without the label form a group of their own.
+func waves(targets []target.Target, topologyKey string, maxInFlight int) [][]target.Target {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	groups := make(map[string][]target.Target)
+	for _, t := range targets {
+		key := t.Labels[topologyKey]
+		groups[key] = append(groups[key], t)
+	}
+	keys := slices.Sorted(maps.Keys(groups))
+
+	var result [][]target.Target
+	for {
+		var wave []target.Target
+		for _, key := range keys {
+			g := groups[key]
+			n := min(maxInFlight, len(g))
+			wave = append(wave, g[:n]...)
+			groups[key] = g[n:]
+		}
+		if len(wave) == 0 {
+			return result
 		}
+		result = append(result, wave)
 	}
-	return false
+}
+
+// targetSource get the source of the targets of the schedule.
+func (j *cronJob) targetSource(sc config.Schedule) (target.Source, error) {
+	ts := sc.Targets
+	switch {
+	case ts.IsNodes():
+		return target.Nodes(j.client, sc.JobNodeSelector, j.cfg.RunOnUnscheduledNodes, sc.MatchesNode), nil
+	case ts.Static != nil:
+		return target.Static(ts.Static), nil
+	case ts.ConfigMap != nil:
+		return target.ConfigMap(j.client, j.cfg.Namespace, ts.ConfigMap.Name, ts.ConfigMap.Key), nil
+	case ts.URL != "":
+		return target.HTTP(ts.URL, ts.Headers, ts.RequestTimeout()), nil
+	}
+	selector, err := labels.Parse(ts.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return target.Objects(j.client, ts.GroupVersionKind(), ts.Namespace, selector), nil
+}
+
+// filterTargets limit the targets to the ones matching the trigger options.
+func filterTargets(targets []target.Target, opts lifecycle.TriggerOptions) ([]target.Target, error) {
+	if len(opts.Nodes) == 0 && opts.Selector == "" {
+		return targets, nil
+	}
+	selector, err := labels.Parse(opts.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []target.Target
+	for _, t := range targets {
+		if len(opts.Nodes) > 0 && !slices.Contains(opts.Nodes, t.ID()) {
+			continue
+		}
+		if selector.Matches(labels.Set(t.Labels)) {
+			filtered = append(filtered, t)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, lifecycle.ErrNoMatchingNodes
+	}
+	return filtered, nil
 }
 
 type podJob struct {
@@ -173,7 +851,11 @@ type podJob struct {
 	nodeName string
 	log      logr.Logger
 	pod      *corev1.Pod
-	client   client.Client
+	// batch the batch job wrapping the pod in the job mode Job, pod is nil then
+	batch  *batchv1.Job
+	client client.Client
+	// create the job of an attempt from the template, nil for restored jobs
+	create func(attempt int) (*podJob, error)
 }
 
 func (j *podJob) ID() string {
@@ -184,11 +866,88 @@ func (j *podJob) Node() string {
 	return j.nodeName
 }
 
+// Retry get the job of the given attempt with a new pod. The pod is rendered from the template for the attempt, the
+// pod of the previous attempt is copied if the job can not be rendered.
+func (j *podJob) Retry(attempt int) lifecycle.Job {
+	if j.create != nil {
+		retry, err := j.create(attempt)
+		if err == nil {
+			retry.setAttempt(attempt)
+			return retry
+		}
+		log.Error(err, "error creating pod of retry from template", "node", j.nodeName)
+	}
+	retry := &podJob{
+		id:       j.id,
+		nodeName: j.nodeName,
+		log:      j.log,
+		client:   j.client,
+		create:   j.create,
+		pod:      j.pod.DeepCopy(),
+		batch:    j.batch.DeepCopy(),
+	}
+	retry.setAttempt(attempt)
+	return retry
+}
+
+// setAttempt reset the object of the job for the given attempt.
+func (j *podJob) setAttempt(attempt int) {
+	if j.batch == nil {
+		retryMeta(&j.pod.ObjectMeta, attempt)
+		j.pod.Status = corev1.PodStatus{}
+		return
+	}
+	bj := j.batch
+	retryMeta(&bj.ObjectMeta, attempt)
+	bj.Name = job.BatchName(bj.Name)
+	bj.Status = batchv1.JobStatus{}
+	// the selector and its labels are generated for the new job
+	bj.Spec.Selector = nil
+	bj.Spec.ManualSelector = nil
+	for _, l := range []string{batchv1.ControllerUidLabel, batchv1.JobNameLabel, "controller-uid", "job-name"} {
+		delete(bj.Spec.Template.Labels, l)
+	}
+	if bj.Spec.Template.Labels == nil {
+		bj.Spec.Template.Labels = make(map[string]string)
+	}
+	bj.Spec.Template.Labels[controller.LabelAttempt] = strconv.Itoa(attempt)
+}
+
+// retryMeta reset the metadata of a job object for the given attempt.
+func retryMeta(meta *metav1.ObjectMeta, attempt int) {
+	meta.Name = fmt.Sprintf("%s-retry-%d", retrySuffix.ReplaceAllString(meta.Name, ""), attempt)
+	meta.ResourceVersion = ""
+	meta.UID = ""
+	meta.CreationTimestamp = metav1.Time{}
+	meta.DeletionTimestamp = nil
+	if meta.Labels == nil {
+		meta.Labels = make(map[string]string)
+	}
+	meta.Labels[controller.LabelAttempt] = strconv.Itoa(attempt)
+}
+
+// object get the object of the job, the batch job in the job mode Job.
+func (j *podJob) object() client.Object {
+	if j.batch != nil {
+		return j.batch
+	}
+	return j.pod
+}
+
 // CreatePod create a worker pod.
 func (j *podJob) CreatePod() {
 	log.Info("create pod", "node", j.nodeName)
-	err := j.client.Create(context.TODO(), j.pod)
+	err := j.client.Create(context.TODO(), j.object())
 	if err != nil {
 		log.Error(err, "unable to create pod", "node", j.nodeName)
 	}
 }
+
+// DeletePod delete the worker pod.
+func (j *podJob) DeletePod() {
+	log.Info("delete pod", "node", j.nodeName)
+	err := j.client.Delete(context.TODO(), j.object(), client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.Error(err, "unable to delete pod", "node", j.nodeName)
+	}
+}
@@ -3,20 +3,33 @@ package cron
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	gm "go.uber.org/mock/gomock"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"github.com/bakito/batch-job-controller/pkg/config"
+	"github.com/bakito/batch-job-controller/pkg/controller"
 	"github.com/bakito/batch-job-controller/pkg/job"
+	"github.com/bakito/batch-job-controller/pkg/lifecycle"
+	"github.com/bakito/batch-job-controller/pkg/metrics"
 	mockclient "github.com/bakito/batch-job-controller/pkg/mocks/client"
+	mockevents "github.com/bakito/batch-job-controller/pkg/mocks/events"
 	mocklifecycle "github.com/bakito/batch-job-controller/pkg/mocks/lifecycle"
 	mocklogr "github.com/bakito/batch-job-controller/pkg/mocks/logr"
+	"github.com/bakito/batch-job-controller/pkg/target"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -32,6 +45,8 @@ var _ = Describe("Cron", func() {
 		namespace      string
 		configName     string
 		id             string
+		// finished the channel of a finished execution
+		finished <-chan struct{}
 	)
 	BeforeEach(func() {
 		mockCtrl = gm.NewController(GinkgoT())
@@ -41,9 +56,13 @@ var _ = Describe("Cron", func() {
 		namespace = uuid.New().String()
 		configName = uuid.New().String()
 		id = uuid.New().String()
+		done := make(chan struct{})
+		close(done)
+		finished = done
 		cfg := &config.Config{
-			Name:      configName,
-			Namespace: namespace,
+			Name:            configName,
+			Namespace:       namespace,
+			ReportDirectory: GinkgoT().TempDir(),
 		}
 		mockSink.EXPECT().Init(gm.Any())
 		mockSink.EXPECT().Enabled(gm.Any()).AnyTimes().Return(true)
@@ -55,6 +74,13 @@ var _ = Describe("Cron", func() {
 		cj.InjectConfig(cfg)
 		cj.InjectClient(mockClient)
 	})
+	running := func(s *schedule) func() bool {
+		return func() bool {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+			return s.running
+		}
+	}
 	Context("NeedLeaderElection", func() {
 		It("should be true", func() {
 			needLE := cj.NeedLeaderElection()
@@ -66,25 +92,85 @@ var _ = Describe("Cron", func() {
 			mockClient.EXPECT().
 				DeleteAllOf(gm.Any(), gm.AssignableToTypeOf(&corev1.Pod{}), client.InNamespace(namespace), job.MatchingLabels(configName), client.PropagationPolicy(metav1.DeletePropagationBackground))
 
-			err := cj.deleteAll(&corev1.Pod{})
+			err := cj.deleteAll(&corev1.Pod{}, "")
 			Ω(err).ShouldNot(HaveOccurred())
 		})
 	})
 
+	Context("restore", func() {
+		It("should restore the executions of existing job pods", func() {
+			mockClient.EXPECT().
+				List(gm.Any(), gm.AssignableToTypeOf(&corev1.PodList{}), client.InNamespace(namespace), job.MatchingLabels(configName)).
+				Do(func(_ context.Context, list *corev1.PodList, _ ...client.ListOption) error {
+					list.Items = []corev1.Pod{
+						{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{controller.LabelExecutionID: id}}},
+						{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{controller.LabelExecutionID: id}}},
+					}
+					return nil
+				})
+			mockSink.EXPECT().WithValues("id", id, "pods", 2).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "restoring execution")
+			mockController.EXPECT().Restore(id, gm.Len(2))
+
+			err := cj.restore(context.TODO())
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should restore the executions of existing batch jobs in job mode", func() {
+			cj.cfg.JobMode = config.JobModeJob
+			mockClient.EXPECT().
+				List(gm.Any(), gm.AssignableToTypeOf(&batchv1.JobList{}), client.InNamespace(namespace), job.MatchingLabels(configName)).
+				Do(func(_ context.Context, list *batchv1.JobList, _ ...client.ListOption) error {
+					list.Items = []batchv1.Job{{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels:      map[string]string{controller.LabelExecutionID: id, controller.LabelAttempt: "1"},
+							Annotations: map[string]string{controller.AnnotationTarget: "node-a"},
+						},
+						Status: batchv1.JobStatus{
+							Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+						},
+					}}
+					return nil
+				})
+			mockSink.EXPECT().WithValues("id", id, "jobs", 1).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "restoring execution")
+			mockController.EXPECT().Restore(id, gm.Len(1)).
+				DoAndReturn(func(_ string, pods []lifecycle.RestoredPod) error {
+					Ω(pods[0].Job.Node()).Should(Equal("node-a"))
+					Ω(pods[0].Pod.Status.Phase).Should(Equal(corev1.PodSucceeded))
+					Ω(pods[0].Attempt).Should(Equal(1))
+					Ω(pods[0].Job.(*podJob).batch).ShouldNot(BeNil())
+					return nil
+				})
+
+			err := cj.restore(context.TODO())
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should return an error if pods can not be listed", func() {
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.PodList{}), gm.Any(), gm.Any()).
+				Return(errors.New("error"))
+
+			err := cj.restore(context.TODO())
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
 	Context("startPods", func() {
 		var nodeSelector map[string]string
 		BeforeEach(func() {
 			nodeSelector = map[string]string{"foo": "bar"}
 			cj.cfg.JobNodeSelector = nodeSelector
 			cj.cfg.JobPodTemplate = "kind: Pod"
-			mockController.EXPECT().NewExecution(1).Return(id)
+			mockController.EXPECT().NewExecution("", 1).Return(id)
 			mockController.EXPECT().AllAdded(gm.Any())
+			mockController.EXPECT().Finished(id).Return(finished, nil)
 			mockController.EXPECT().AddPod(gm.Any())
+			mockController.EXPECT().Executions()
 			mockClient.EXPECT().DeleteAllOf(gm.Any(), gm.Any(), gm.Any(), gm.Any(), gm.Any())
 			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), client.MatchingLabels(nodeSelector)).
 				Do(func(_ context.Context, list *corev1.NodeList, _ ...client.ListOption) error {
 					list.Items = []corev1.Node{
 						{
+							ObjectMeta: metav1.ObjectMeta{Labels: nodeSelector},
 							Spec: corev1.NodeSpec{
 								Unschedulable: false,
 							},
@@ -105,9 +191,10 @@ var _ = Describe("Cron", func() {
 			cj.cfg.CallbackServiceName = "any-service-name"
 			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Service{}))
 			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
 			mockSink.EXPECT().Info(gm.Any(), "deleting old job pods")
 			mockSink.EXPECT().Info(gm.Any(), "executing job")
-			cj.startPods()
+			cj.startPods("")
 		})
 		It("should start all pods with pod IP for callback", func() {
 			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
@@ -115,17 +202,1043 @@ var _ = Describe("Cron", func() {
 				_ = os.Unsetenv(config.EnvPodIP)
 			}()
 			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
 			mockSink.EXPECT().Info(gm.Any(), "deleting old job pods")
 			mockSink.EXPECT().Info(gm.Any(), "executing job")
-			cj.startPods()
+			cj.startPods("")
 		})
 	})
 
 	Context("startPods - already running", func() {
 		It("should not start all pods", func() {
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "last cronjob still running")
+			cj.schedules[""].running = true
+			cj.startPods("")
+		})
+	})
+
+	Context("Trigger", func() {
+		It("should return an error if already running", func() {
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "last cronjob still running")
+			cj.schedules[""].running = true
+			_, err := cj.Trigger(lifecycle.TriggerOptions{})
+			Ω(err).Should(MatchError(lifecycle.ErrExecutionRunning))
+		})
+		It("should return an error if no node matches", func() {
+			mockSink.EXPECT().Error(lifecycle.ErrNoMatchingNodes, "error filtering targets")
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), gm.Any())
+			_, err := cj.Trigger(lifecycle.TriggerOptions{Nodes: []string{"node-a"}})
+			Ω(err).Should(MatchError(lifecycle.ErrNoMatchingNodes))
+			Ω(cj.schedules[""].running).Should(BeFalse())
+		})
+		It("should return an error if the schedule is not known", func() {
+			_, err := cj.Trigger(lifecycle.TriggerOptions{Schedule: "foo"})
+			Ω(err).Should(MatchError(lifecycle.ErrUnknownSchedule))
+		})
+		It("should start the execution of a named schedule", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			cj.InjectConfig(&config.Config{
+				Name:      configName,
+				Namespace: namespace,
+				Schedules: []config.Schedule{
+					{Name: "nightly"},
+					{Name: "hourly", JobNodeSelector: map[string]string{"size": "small"}},
+				},
+			})
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), client.MatchingLabels{"size": "small"}).
+				Do(func(_ context.Context, list *corev1.NodeList, _ ...client.ListOption) error {
+					list.Items = []corev1.Node{{
+						ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{"size": "small"}},
+						Status: corev1.NodeStatus{
+							Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+						},
+					}}
+					return nil
+				})
+			labels := job.MatchingLabels(configName)
+			labels[controller.LabelSchedule] = "hourly"
+			mockClient.EXPECT().DeleteAllOf(gm.Any(), gm.Any(), client.InNamespace(namespace), labels, gm.Any())
+			mockController.EXPECT().NewExecution("hourly", 1).Return(id)
+			mockController.EXPECT().Executions()
+			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+			mockSink.EXPECT().WithValues("schedule", "hourly").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "deleting old job pods")
+
+			r, err := cj.prepare(cj.schedules["hourly"], lifecycle.TriggerOptions{}, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(r.schedule.Name).Should(Equal("hourly"))
+			Ω(cj.schedules["hourly"].running).Should(BeTrue())
+			Ω(cj.schedules["nightly"].running).Should(BeFalse())
+		})
+		It("should cancel the unfinished executions of the schedule before deleting the old pods", func() {
+			now := time.Now()
+			mockController.EXPECT().Executions().Return([]*lifecycle.ExecutionStatus{
+				{ID: "202001021504"},
+				{ID: "202001011504", Finished: &now},
+				{ID: "hourly-202001021504", Schedule: "hourly"},
+			})
+			mockController.EXPECT().Cancel("202001021504", "replaced by a new execution")
+
+			cj.cancelOld("")
+		})
+		It("should not create the execution if the callback service can not be resolved", func() {
+			cj.cfg.JobPodTemplate = "kind: Pod"
+			cj.cfg.CallbackServiceName = "any-service-name"
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), gm.Any())
+			mockClient.EXPECT().Get(gm.Any(), gm.Any(), gm.AssignableToTypeOf(&corev1.Service{})).Return(errors.New("error"))
+			mockSink.EXPECT().WithValues("service-name", "any-service-name").Return(mockSink)
+			mockSink.EXPECT().Error(gm.Any(), "error getting service")
+
+			_, err := cj.Trigger(lifecycle.TriggerOptions{})
+			Ω(err).Should(HaveOccurred())
+			Ω(cj.schedules[""].running).Should(BeFalse())
+		})
+		It("should not create the execution if the old pods can not be deleted", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), gm.Any())
+			mockController.EXPECT().Executions()
+			mockClient.EXPECT().DeleteAllOf(gm.Any(), gm.Any(), gm.Any(), gm.Any(), gm.Any()).Return(errors.New("error"))
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "deleting old job pods")
+			mockSink.EXPECT().Error(gm.Any(), "unable to delete old pods")
+
+			_, err := cj.Trigger(lifecycle.TriggerOptions{})
+			Ω(err).Should(HaveOccurred())
+			Ω(cj.schedules[""].running).Should(BeFalse())
+		})
+		It("should start a rerun as partial execution linked to the original one", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), gm.Any()).
+				Do(func(_ context.Context, list *corev1.NodeList, _ ...client.ListOption) error {
+					list.Items = []corev1.Node{{
+						ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+						Status: corev1.NodeStatus{
+							Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+						},
+					}}
+					return nil
+				})
+			// a rerun is partial, the original execution and the pods of the other nodes are kept
+			mockController.EXPECT().NewPartialExecution("", 1).Return(id)
+			mockController.EXPECT().LinkRerun("202001021504", id)
+			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+
+			opts := lifecycle.TriggerOptions{Nodes: []string{"node-a"}, RerunOf: "202001021504"}
+			r, err := cj.prepare(cj.schedules[""], opts, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(r.id).Should(Equal(id))
+			Ω(r.targets).Should(HaveLen(1))
+		})
+		It("should exclude the nodes not matching the node filter", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			cj.cfg.JobNodeFilter = config.NodeFilter{
+				ExcludedConditions: []corev1.NodeConditionType{corev1.NodeDiskPressure},
+				Exclude:            []string{"node-c"},
+			}
+			ready := corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue}
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), gm.Any()).
+				Do(func(_ context.Context, list *corev1.NodeList, _ ...client.ListOption) error {
+					list.Items = []corev1.Node{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+							Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{ready}},
+						},
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "node-b"},
+							Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+								ready,
+								{Type: corev1.NodeDiskPressure, Status: corev1.ConditionTrue},
+							}},
+						},
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "node-c"},
+							Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{ready}},
+						},
+					}
+					return nil
+				})
+			mockClient.EXPECT().DeleteAllOf(gm.Any(), gm.Any(), gm.Any(), gm.Any(), gm.Any())
+			mockController.EXPECT().NewExecution("", 1).Return(id)
+			mockController.EXPECT().Executions()
+			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "deleting old job pods")
+
+			r, err := cj.prepare(cj.schedules[""], lifecycle.TriggerOptions{}, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(r.targets).Should(HaveLen(1))
+			Ω(r.targets[0].Name).Should(Equal("node-a"))
+		})
+		It("should list the objects of the target source", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			cj.cfg.Targets = config.TargetSource{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "app"}
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&unstructured.UnstructuredList{}), gm.Any(), gm.Any()).
+				Do(func(_ context.Context, list *unstructured.UnstructuredList, _ ...client.ListOption) error {
+					o := unstructured.Unstructured{}
+					o.SetName("data")
+					o.SetNamespace("app")
+					list.Items = []unstructured.Unstructured{o}
+					return nil
+				})
+			mockClient.EXPECT().DeleteAllOf(gm.Any(), gm.Any(), gm.Any(), gm.Any(), gm.Any())
+			mockController.EXPECT().NewExecution("", 1).Return(id)
+			mockController.EXPECT().Executions()
+			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "deleting old job pods")
+
+			r, err := cj.prepare(cj.schedules[""], lifecycle.TriggerOptions{Nodes: []string{"app.data"}}, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(r.targets).Should(HaveLen(1))
+			Ω(r.targets[0].ID()).Should(Equal("app.data"))
+			Ω(r.targets[0].IsNode()).Should(BeFalse())
+		})
+		It("should list the static targets", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			cj.cfg.Targets = config.TargetSource{Static: []any{"shard-1", map[string]any{"name": "shard-2"}}}
+			mockClient.EXPECT().DeleteAllOf(gm.Any(), gm.Any(), gm.Any(), gm.Any(), gm.Any())
+			mockController.EXPECT().NewExecution("", 2).Return(id)
+			mockController.EXPECT().Executions()
+			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "deleting old job pods")
+
+			r, err := cj.prepare(cj.schedules[""], lifecycle.TriggerOptions{}, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(target.IDs(r.targets)).Should(Equal([]string{"shard-1", "shard-2"}))
+		})
+	})
+
+	Context("acquire", func() {
+		var s *schedule
+		BeforeEach(func() {
+			s = cj.schedules[""]
+		})
+		It("should acquire an idle schedule", func() {
+			gen, err := cj.acquire(s, config.ConcurrencyPolicyForbid, true)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(s.running).Should(BeTrue())
+			s.release(gen)
+			Ω(s.running).Should(BeFalse())
+		})
+		It("should forbid a new execution while running", func() {
+			_, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "last cronjob still running")
+
+			_, err = cj.acquire(s, config.ConcurrencyPolicyForbid, true)
+			Ω(err).Should(MatchError(lifecycle.ErrExecutionRunning))
+		})
+		It("should replace the running execution", func() {
+			gen1, err := cj.acquire(s, config.ConcurrencyPolicyReplace, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			s.started(gen1, id)
+			mockSink.EXPECT().WithValues("schedule", "", "id", id).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "replacing running execution")
+			mockController.EXPECT().Cancel(id, "replaced by a new execution")
+
+			gen2, err := cj.acquire(s, config.ConcurrencyPolicyReplace, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(gen2).ShouldNot(Equal(gen1))
+
+			// the replaced execution must not release the schedule
+			s.release(gen1)
+			Ω(s.running).Should(BeTrue())
+			s.release(gen2)
+			Ω(s.running).Should(BeFalse())
+		})
+		It("should hold the schedule until the dispatched execution is finished", func() {
+			gen, err := cj.acquire(s, config.ConcurrencyPolicyReplace, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			s.started(gen, id)
+			done := make(chan struct{})
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return((<-chan struct{})(done), nil)
+
+			sc, _ := cj.cfg.ScheduleFor("")
+			cj.dispatch(s, &run{id: id, generation: gen, schedule: sc, log: log, dispatched: map[string]bool{}})
+			// the pods of the execution are still running
+			Consistently(running(s), "50ms").Should(BeTrue())
+
+			mockSink.EXPECT().WithValues("schedule", "", "id", id).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "replacing running execution")
+			mockController.EXPECT().Cancel(id, "replaced by a new execution").Do(func(string, string) {
+				close(done)
+			})
+			gen2, err := cj.acquire(s, config.ConcurrencyPolicyReplace, false)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			// the replaced execution must not release the schedule
+			Consistently(running(s), "50ms").Should(BeTrue())
+			s.release(gen2)
+			Ω(running(s)()).Should(BeFalse())
+		})
+		It("should forbid a new execution while the pods of the dispatched execution are running", func() {
+			gen, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			s.started(gen, id)
+			done := make(chan struct{})
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return((<-chan struct{})(done), nil)
+
+			sc, _ := cj.cfg.ScheduleFor("")
+			cj.dispatch(s, &run{id: id, generation: gen, schedule: sc, log: log, dispatched: map[string]bool{}})
+
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "last cronjob still running")
+			_, err = cj.acquire(s, config.ConcurrencyPolicyForbid, true)
+			Ω(err).Should(MatchError(lifecycle.ErrExecutionRunning))
+
+			close(done)
+			Eventually(running(s)).Should(BeFalse())
+		})
+		It("should not replace an execution that is still prepared", func() {
+			_, err := cj.acquire(s, config.ConcurrencyPolicyReplace, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
 			mockSink.EXPECT().Info(gm.Any(), "last cronjob still running")
-			cj.running = true
-			cj.startPods()
+
+			_, err = cj.acquire(s, config.ConcurrencyPolicyReplace, false)
+			Ω(err).Should(MatchError(lifecycle.ErrExecutionRunning))
+		})
+		It("should queue a scheduled execution until the running one is released", func() {
+			gen1, err := cj.acquire(s, config.ConcurrencyPolicyQueue, true)
+			Ω(err).ShouldNot(HaveOccurred())
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink).Times(2)
+			mockSink.EXPECT().Info(gm.Any(), "queueing execution")
+			mockSink.EXPECT().Info(gm.Any(), "an execution is already queued")
+
+			acquired := make(chan uint64)
+			go func() {
+				defer GinkgoRecover()
+				gen, err := cj.acquire(s, config.ConcurrencyPolicyQueue, true)
+				Ω(err).ShouldNot(HaveOccurred())
+				acquired <- gen
+			}()
+			Eventually(func() bool {
+				s.mux.Lock()
+				defer s.mux.Unlock()
+				return s.queued
+			}).Should(BeTrue())
+
+			// only one execution is queued
+			_, err = cj.acquire(s, config.ConcurrencyPolicyQueue, true)
+			Ω(err).Should(MatchError(lifecycle.ErrExecutionRunning))
+			Consistently(acquired, "50ms").ShouldNot(Receive())
+
+			s.release(gen1)
+			var gen2 uint64
+			Eventually(acquired).Should(Receive(&gen2))
+			Ω(gen2).Should(Equal(gen1 + 1))
+			Ω(s.queued).Should(BeFalse())
+		})
+		It("should not queue an on demand execution", func() {
+			_, err := cj.acquire(s, config.ConcurrencyPolicyQueue, true)
+			Ω(err).ShouldNot(HaveOccurred())
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "last cronjob still running")
+
+			_, err = cj.acquire(s, config.ConcurrencyPolicyQueue, false)
+			Ω(err).Should(MatchError(lifecycle.ErrExecutionRunning))
+		})
+	})
+
+	Context("catchUp", func() {
+		var s *schedule
+		BeforeEach(func() {
+			cj.location = time.UTC
+			cj.cfg.CronExpression = "0 3 * * *"
+			s = cj.schedules[""]
+			var err error
+			s.spec, err = config.CronParser.Parse("0 3 * * *")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+		It("should return the latest missed run within the deadline", func() {
+			now := time.Date(2020, 1, 2, 4, 0, 0, 0, time.UTC)
+			last := time.Date(2019, 12, 30, 3, 0, 0, 0, time.UTC)
+
+			missed, ok := missedRun(s.spec, last, now, 2*time.Hour)
+			Ω(ok).Should(BeTrue())
+			Ω(missed).Should(Equal(time.Date(2020, 1, 2, 3, 0, 0, 0, time.UTC)))
+
+			_, ok = missedRun(s.spec, last, now, 30*time.Minute)
+			Ω(ok).Should(BeFalse())
+			_, ok = missedRun(s.spec, time.Date(2020, 1, 2, 3, 0, 5, 0, time.UTC), now, 2*time.Hour)
+			Ω(ok).Should(BeFalse())
+		})
+		It("should record a baseline if no start is recorded", func() {
+			cj.catchUp()
+
+			starts, err := cj.loadStarts()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(starts).Should(HaveKey(""))
+			Ω(starts[""]).Should(BeTemporally("~", time.Now(), time.Second))
+		})
+		It("should record the start of a schedule", func() {
+			t := time.Date(2020, 1, 2, 3, 0, 0, 0, time.UTC)
+			cj.recordStart("", t)
+
+			starts, err := cj.loadStarts()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(starts[""]).Should(BeTemporally("==", t))
+		})
+		It("should start a missed execution within the deadline", func() {
+			cj.cfg.StartingDeadline = metav1.Duration{Duration: 48 * time.Hour}
+			cj.recordStart("", time.Now().Add(-72*time.Hour))
+			s.running = true
+
+			started := make(chan bool)
+			mockSink.EXPECT().WithValues("schedule", "", "missed", gm.Any(), "lastStart", gm.Any()).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "starting missed execution")
+			// the missed execution is forbidden by the running one
+			mockSink.EXPECT().WithValues("schedule", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "last cronjob still running").Do(func(_ int, _ string, _ ...any) {
+				started <- true
+			})
+
+			cj.catchUp()
+			Eventually(started).Should(Receive())
+			// the rejected execution is recorded as handled
+			Eventually(func() time.Time {
+				starts, _ := cj.loadStarts()
+				return starts[""]
+			}).Should(BeTemporally("~", time.Now(), time.Second))
+		})
+		It("should record an execution skipped within a maintenance window", func() {
+			cj.cfg.MaintenanceWindows = []config.MaintenanceWindow{{
+				Name:   "freeze",
+				Start:  &metav1.Time{Time: time.Now().Add(-time.Hour)},
+				End:    &metav1.Time{Time: time.Now().Add(time.Hour)},
+				Action: config.MaintenanceActionSkip,
+			}}
+			prom, err := metrics.NewPromCollector(&config.Config{Metrics: config.Metrics{Prefix: "cron_test"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			cj.InjectMetrics(prom)
+			cj.recordStart("", time.Now().Add(-72*time.Hour))
+			mockSink.EXPECT().WithValues("schedule", "", "window", "freeze", "end", gm.Any()).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "skipping execution within maintenance window")
+
+			cj.startPods("")
+
+			starts, err := cj.loadStarts()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(starts[""]).Should(BeTemporally("~", time.Now(), time.Second))
+
+			// the skipped execution is not caught up
+			cj.cfg.StartingDeadline = metav1.Duration{Duration: 48 * time.Hour}
+			cj.catchUp()
+		})
+		It("should not start a missed execution without deadline", func() {
+			cj.recordStart("", time.Now().Add(-72*time.Hour))
+			cj.catchUp()
+		})
+	})
+
+	Context("nextRun", func() {
+		var prom *metrics.Collector
+		BeforeEach(func() {
+			var err error
+			prom, err = metrics.NewPromCollector(&config.Config{Metrics: config.Metrics{Prefix: "cron_test"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			cj.InjectMetrics(prom)
+		})
+		It("should record the next run in the configured time zone", func() {
+			loc, err := time.LoadLocation("Europe/Zurich")
+			Ω(err).ShouldNot(HaveOccurred())
+			cj.location = loc
+			s := cj.schedules[""]
+			s.spec, err = config.CronParser.Parse("0 3 * * *")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			var next string
+			mockSink.EXPECT().WithValues("schedule", "", "next", gm.Any()).
+				DoAndReturn(func(kv ...any) logr.LogSink {
+					next = kv[3].(string)
+					return mockSink
+				})
+			mockSink.EXPECT().Info(gm.Any(), "next run")
+
+			cj.nextRun(s)
+
+			t, err := time.Parse(time.RFC3339, next)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(t.After(time.Now())).Should(BeTrue())
+			Ω(t.In(loc).Hour()).Should(Equal(3))
+			Ω(t.In(loc).Minute()).Should(Equal(0))
+			Ω(testutil.CollectAndCompare(prom, strings.NewReader(fmt.Sprintf(`
+				# HELP cron_test_next_execution_timestamp The unix timestamp of the next scheduled execution
+				# TYPE cron_test_next_execution_timestamp gauge
+				cron_test_next_execution_timestamp{schedule=""} %d
+			`, t.Unix())), "cron_test_next_execution_timestamp")).ShouldNot(HaveOccurred())
+		})
+		It("should delay a scheduled execution by the jitter", func() {
+			cj.location = time.UTC
+			cj.cfg.Jitter = metav1.Duration{Duration: 10 * time.Millisecond}
+			s := &schedule{name: "unknown"}
+			var err error
+			s.spec, err = config.CronParser.Parse("*/30 * * * * *")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			mockSink.EXPECT().WithValues("schedule", "unknown", "next", gm.Any()).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "next run")
+			mockSink.EXPECT().WithValues("schedule", "unknown", "delay", gm.Any()).
+				DoAndReturn(func(kv ...any) logr.LogSink {
+					d, err := time.ParseDuration(kv[3].(string))
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(d).Should(BeNumerically("<", 10*time.Millisecond))
+					return mockSink
+				})
+			mockSink.EXPECT().Info(gm.Any(), "delaying scheduled execution")
+
+			cj.tick(s)
+		})
+	})
+
+	Context("awaitMaintenance", func() {
+		var (
+			prom         *metrics.Collector
+			mockRecorder *mockevents.MockEventRecorder
+			owner        *appsv1.Deployment
+		)
+		BeforeEach(func() {
+			var err error
+			prom, err = metrics.NewPromCollector(&config.Config{Metrics: config.Metrics{Prefix: "cron_test"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			mockRecorder = mockevents.NewMockEventRecorder(mockCtrl)
+			owner = &appsv1.Deployment{}
+			cj.InjectMetrics(prom)
+			cj.InjectEventRecorder(mockRecorder)
+			cj.cfg.Owner = owner
+			cj.location = time.UTC
+		})
+		It("should start if no window is active", func() {
+			cj.cfg.MaintenanceWindows = []config.MaintenanceWindow{{
+				Name:  "past",
+				Start: &metav1.Time{Time: time.Now().Add(-2 * time.Hour)},
+				End:   &metav1.Time{Time: time.Now().Add(-time.Hour)},
+			}}
+			Ω(cj.awaitMaintenance(cj.schedules[""])).Should(BeTrue())
+		})
+		It("should skip an execution within a window", func() {
+			cj.cfg.MaintenanceWindows = []config.MaintenanceWindow{{
+				Name:   "freeze",
+				Start:  &metav1.Time{Time: time.Now().Add(-time.Hour)},
+				End:    &metav1.Time{Time: time.Now().Add(time.Hour)},
+				Action: config.MaintenanceActionSkip,
+			}}
+			mockSink.EXPECT().WithValues("schedule", "", "window", "freeze", "end", gm.Any()).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "skipping execution within maintenance window")
+			mockRecorder.EXPECT().Eventf(owner, nil, corev1.EventTypeWarning, eventReasonSkipped, eventActionMaintenance,
+				gm.Any(), "freeze", gm.Any())
+
+			Ω(cj.awaitMaintenance(cj.schedules[""])).Should(BeFalse())
+			Ω(testutil.CollectAndCompare(prom, strings.NewReader(`
+				# HELP cron_test_maintenance_blocked_total The number of scheduled executions blocked by a maintenance window
+				# TYPE cron_test_maintenance_blocked_total counter
+				cron_test_maintenance_blocked_total{action="Skip",schedule="",window="freeze"} 1
+			`), "cron_test_maintenance_blocked_total")).ShouldNot(HaveOccurred())
+		})
+		It("should defer an execution until the window ends", func() {
+			end := time.Now().Add(50 * time.Millisecond)
+			cj.cfg.MaintenanceWindows = []config.MaintenanceWindow{{
+				Name:   "freeze",
+				Start:  &metav1.Time{Time: time.Now().Add(-time.Hour)},
+				End:    &metav1.Time{Time: end},
+				Action: config.MaintenanceActionDefer,
+			}}
+			mockSink.EXPECT().WithValues("schedule", "", "window", "freeze", "end", gm.Any()).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "deferring execution until the end of the maintenance window")
+			mockRecorder.EXPECT().Eventf(owner, nil, corev1.EventTypeNormal, eventReasonDeferred, eventActionMaintenance,
+				gm.Any(), "freeze", gm.Any())
+
+			Ω(cj.awaitMaintenance(cj.schedules[""])).Should(BeTrue())
+			Ω(time.Now()).Should(BeTemporally(">=", end))
+			Ω(cj.schedules[""].deferred.Load()).Should(BeFalse())
+		})
+		It("should skip an execution if one is already deferred", func() {
+			cj.cfg.MaintenanceWindows = []config.MaintenanceWindow{{
+				Name:   "freeze",
+				Start:  &metav1.Time{Time: time.Now().Add(-time.Hour)},
+				End:    &metav1.Time{Time: time.Now().Add(time.Hour)},
+				Action: config.MaintenanceActionDefer,
+			}}
+			cj.schedules[""].deferred.Store(true)
+			mockSink.EXPECT().WithValues("schedule", "", "window", "freeze", "end", gm.Any()).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "execution is already deferred")
+
+			Ω(cj.awaitMaintenance(cj.schedules[""])).Should(BeFalse())
+			Ω(cj.schedules[""].deferred.Load()).Should(BeTrue())
+		})
+	})
+
+	Context("node watcher", func() {
+		var (
+			s    *schedule
+			node corev1.Node
+		)
+		BeforeEach(func() {
+			s = cj.schedules[""]
+			cj.cfg.NodeWatcher.Cooldown.Duration = time.Minute
+			cj.cfg.JobPodTemplate = "kind: Pod"
+			node = corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+				},
+			}
+		})
+		It("should cool down a node", func() {
+			Ω(cj.coolDown("", "node-a")).Should(BeTrue())
+			Ω(cj.coolDown("", "node-a")).Should(BeFalse())
+			Ω(cj.coolDown("", "node-b")).Should(BeTrue())
+			Ω(cj.coolDown("other", "node-a")).Should(BeTrue())
+
+			cj.cooldowns["/node-a"] = time.Now().Add(-time.Minute)
+			Ω(cj.coolDown("", "node-a")).Should(BeTrue())
+		})
+		It("should not acquire an idle schedule for a ready node", func() {
+			Ω(cj.addNode(s, target.FromNode(node))).Should(BeFalse())
+			Ω(s.running).Should(BeFalse())
+			Ω(s.generation).Should(BeZero())
+		})
+		It("should add a ready node to the running execution", func() {
+			_, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cj.addNode(s, target.FromNode(node))).Should(BeTrue())
+			Ω(s.pending).Should(HaveLen(1))
+		})
+		It("should ignore a node that is not ready", func() {
+			node.Status.Conditions = nil
+			cj.nodeReady(node)
+			Ω(s.running).Should(BeFalse())
+			Ω(cj.cooldowns).Should(BeEmpty())
+		})
+		It("should ignore a node not matching the node selector", func() {
+			cj.cfg.JobNodeSelector = map[string]string{"foo": "bar"}
+			cj.nodeReady(node)
+			Ω(s.running).Should(BeFalse())
+			Ω(cj.cooldowns).Should(BeEmpty())
+		})
+		It("should ignore a node excluded by the node filter", func() {
+			cj.cfg.JobNodeFilter.Exclude = []string{"node-a"}
+			cj.nodeReady(node)
+			Ω(s.running).Should(BeFalse())
+			Ω(cj.cooldowns).Should(BeEmpty())
+		})
+		It("should add a ready node to the running execution only once within the cooldown", func() {
+			_, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			mockSink.EXPECT().WithValues("schedule", "", "node", "node-a").Return(mockSink).Times(2)
+			mockSink.EXPECT().Info(gm.Any(), "adding ready node to running execution")
+			mockSink.EXPECT().Info(gm.Any(), "node is cooling down")
+
+			cj.nodeReady(node)
+			cj.nodeReady(node)
+			Ω(s.pending).Should(HaveLen(1))
+		})
+		It("should dispatch the nodes that became ready while dispatching", func() {
+			gen, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			nodeB := *node.DeepCopy()
+			nodeB.Name = "node-b"
+			s.pending = []target.Target{target.FromNode(node), target.FromNode(nodeB)}
+
+			var nodes []string
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockController.EXPECT().AddPod(gm.Any()).Times(2).DoAndReturn(func(j lifecycle.Job) error {
+				nodes = append(nodes, j.Node())
+				return nil
+			})
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return(finished, nil)
+
+			sc, _ := cj.cfg.ScheduleFor("")
+			targets := []target.Target{target.FromNode(node)}
+			r := &run{id: id, generation: gen, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}}
+			cj.dispatch(s, r)
+			Ω(nodes).Should(Equal([]string{"node-a", "node-b"}))
+			Ω(s.pending).Should(BeEmpty())
+			Eventually(running(s)).Should(BeFalse())
+		})
+		It("should start a partial execution of a ready node without deleting old pods", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			done := make(chan struct{})
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), gm.Any()).
+				Do(func(_ context.Context, list *corev1.NodeList, _ ...client.ListOption) error {
+					list.Items = []corev1.Node{node}
+					return nil
+				})
+			mockController.EXPECT().NewPartialExecution("", 1).Return(id)
+			mockController.EXPECT().AddPod(gm.Any())
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return((<-chan struct{})(done), nil)
+			mockSink.EXPECT().WithValues("schedule", "", "node", "node-a").Return(mockSink)
+			mockSink.EXPECT().WithValues("id", id).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+
+			cj.startNode(s, node)
+			Ω(s.running).Should(BeFalse())
+			Ω(s.nodeRun.id).Should(Equal(id))
+
+			// a node becoming ready meanwhile is added to the execution of the ready node
+			nodeB := *node.DeepCopy()
+			nodeB.Name = "node-b"
+			mockController.EXPECT().AddPod(gm.Any()).DoAndReturn(func(j lifecycle.Job) error {
+				Ω(j.ID()).Should(Equal(id))
+				Ω(j.Node()).Should(Equal("node-b"))
+				return nil
+			})
+			Ω(cj.addNode(s, target.FromNode(nodeB))).Should(BeTrue())
+
+			close(done)
+			Eventually(func() *run {
+				s.mux.Lock()
+				defer s.mux.Unlock()
+				return s.nodeRun
+			}).Should(BeNil())
+		})
+		It("should start a scheduled execution while an execution of a ready node is running", func() {
+			_ = os.Setenv(config.EnvPodIP, "1.2.3.4")
+			defer func() {
+				_ = os.Unsetenv(config.EnvPodIP)
+			}()
+			nodeID := "202001021504"
+			done := make(chan struct{})
+			defer close(done)
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink).AnyTimes()
+			mockSink.EXPECT().Info(gm.Any(), gm.Any()).AnyTimes()
+			mockClient.EXPECT().List(gm.Any(), gm.AssignableToTypeOf(&corev1.NodeList{}), gm.Any()).Times(2).
+				Do(func(_ context.Context, list *corev1.NodeList, _ ...client.ListOption) error {
+					list.Items = []corev1.Node{node}
+					return nil
+				})
+			mockController.EXPECT().NewPartialExecution("", 1).Return(nodeID)
+			mockController.EXPECT().AddPod(gm.Any()).Times(2)
+			mockController.EXPECT().AllAdded(nodeID)
+			mockController.EXPECT().Finished(nodeID).Return((<-chan struct{})(done), nil)
+
+			cj.startNode(s, node)
+			Ω(s.running).Should(BeFalse())
+
+			// the execution of the ready node is replaced by the scheduled execution
+			mockController.EXPECT().Executions().Return([]*lifecycle.ExecutionStatus{{ID: nodeID, Partial: true}})
+			mockController.EXPECT().Cancel(nodeID, "replaced by a new execution")
+			mockClient.EXPECT().DeleteAllOf(gm.Any(), gm.Any(), gm.Any(), gm.Any(), gm.Any())
+			mockController.EXPECT().NewExecution("", 1).Return(id)
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return(finished, nil)
+
+			cj.startPods("")
+			Eventually(running(s)).Should(BeFalse())
+			Ω(s.generation).Should(Equal(uint64(1)))
+		})
+		It("should add a ready node to the dispatched execution until it is finished", func() {
+			gen, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			s.started(gen, id)
+			done := make(chan struct{})
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockController.EXPECT().AddPod(gm.Any())
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return((<-chan struct{})(done), nil)
+
+			sc, _ := cj.cfg.ScheduleFor("")
+			targets := []target.Target{target.FromNode(node)}
+			r := &run{id: id, generation: gen, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}}
+			cj.dispatch(s, r)
+
+			nodeB := *node.DeepCopy()
+			nodeB.Name = "node-b"
+			mockController.EXPECT().AddPod(gm.Any()).DoAndReturn(func(j lifecycle.Job) error {
+				Ω(j.ID()).Should(Equal(id))
+				Ω(j.Node()).Should(Equal("node-b"))
+				// the schedule is not locked while the job is added
+				Ω(s.mux.TryLock()).Should(BeTrue())
+				s.mux.Unlock()
+				return nil
+			})
+			Ω(cj.addNode(s, target.FromNode(nodeB))).Should(BeTrue())
+			Ω(s.pending).Should(BeEmpty())
+
+			// a node of the execution is not added again
+			Ω(cj.addNode(s, target.FromNode(node))).Should(BeTrue())
+			Ω(r.dispatched).Should(HaveLen(2))
+
+			close(done)
+			Eventually(running(s)).Should(BeFalse())
+		})
+		It("should start a partial execution if the dispatched execution finished meanwhile", func() {
+			gen, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			s.started(gen, id)
+			sc, _ := cj.cfg.ScheduleFor("")
+			s.dispatched = &run{id: id, generation: gen, schedule: sc, log: log, dispatched: map[string]bool{}}
+			mockController.EXPECT().AddPod(gm.Any()).Return(lifecycle.ErrExecutionFinished)
+
+			Ω(cj.addNode(s, target.FromNode(node))).Should(BeFalse())
+			Ω(s.generation).Should(Equal(gen))
+			Ω(s.dispatched.dispatched).Should(BeEmpty())
+
+			// the finished execution releases the schedule
+			s.release(gen)
+			Ω(s.running).Should(BeFalse())
+		})
+		It("should pass nodes created after the watcher was started", func() {
+			p := &nodePredicate{since: time.Now()}
+			node.CreationTimestamp = metav1.NewTime(p.since.Add(-time.Second))
+			Ω(p.Create(event.CreateEvent{Object: &node})).Should(BeFalse())
+			node.CreationTimestamp = metav1.NewTime(p.since.Add(time.Second))
+			Ω(p.Create(event.CreateEvent{Object: &node})).Should(BeTrue())
+		})
+		It("should pass nodes whose readiness changed", func() {
+			p := &nodePredicate{}
+			notReady := node.DeepCopy()
+			notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+			Ω(p.Update(event.UpdateEvent{ObjectOld: notReady, ObjectNew: &node})).Should(BeTrue())
+			Ω(p.Update(event.UpdateEvent{ObjectOld: &node, ObjectNew: node.DeepCopy()})).Should(BeFalse())
+
+			cordoned := node.DeepCopy()
+			cordoned.Spec.Unschedulable = true
+			Ω(p.Update(event.UpdateEvent{ObjectOld: cordoned, ObjectNew: &node})).Should(BeTrue())
+		})
+	})
+
+	Context("rollout", func() {
+		var (
+			targets []target.Target
+			sc      config.Schedule
+		)
+		node := func(name, zone string) target.Target {
+			t := target.Target{Kind: target.KindNode, Name: name, Labels: map[string]string{}}
+			if zone != "" {
+				t.Labels["zone"] = zone
+			}
+			return t
+		}
+		BeforeEach(func() {
+			targets = []target.Target{node("a1", "a"), node("b1", "b"), node("a2", "a"), node("a3", "a"), node("x1", "")}
+			cj.cfg.JobPodTemplate = "kind: Pod"
+			maxFailed := 50
+			cj.cfg.Rollout = config.Rollout{TopologyKey: "zone", MaxInFlight: 2, MaxFailedPercentage: &maxFailed}
+			sc, _ = cj.cfg.ScheduleFor("")
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink).AnyTimes()
+		})
+		It("should split the nodes into waves by topology", func() {
+			w := waves(targets, "zone", 2)
+			Ω(w).Should(HaveLen(2))
+			Ω(target.IDs(w[0])).Should(Equal([]string{"x1", "a1", "a2", "b1"}))
+			Ω(target.IDs(w[1])).Should(Equal([]string{"a3"}))
+
+			w = waves(targets, "zone", 0)
+			Ω(w).Should(HaveLen(3))
+			Ω(target.IDs(w[0])).Should(Equal([]string{"x1", "a1", "b1"}))
+		})
+		It("should roll out all waves", func() {
+			var added []string
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockSink.EXPECT().Info(gm.Any(), "starting wave").Times(2)
+			mockController.EXPECT().AddPod(gm.Any()).Times(5).DoAndReturn(func(j lifecycle.Job) error {
+				added = append(added, j.Node())
+				return nil
+			})
+			mockController.EXPECT().AwaitNodes(id, []string{"x1", "a1", "a2", "b1"}).Return(2, nil)
+			mockController.EXPECT().AwaitNodes(id, []string{"a3"}).Return(1, nil)
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return(finished, nil)
+
+			cj.dispatch(cj.schedules[""], &run{id: id, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}})
+			Ω(added).Should(Equal([]string{"x1", "a1", "a2", "b1", "a3"}))
+		})
+		It("should halt the remaining waves if too many nodes failed", func() {
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockSink.EXPECT().Info(gm.Any(), "starting wave")
+			mockSink.EXPECT().Info(gm.Any(), "halting rollout")
+			mockController.EXPECT().AddPod(gm.Any()).Times(5)
+			mockController.EXPECT().AwaitNodes(id, gm.Any()).Return(3, nil)
+			mockController.EXPECT().Cancel(id, "rollout halted: 3 of 4 nodes of wave 1 failed")
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return(finished, nil)
+
+			cj.dispatch(cj.schedules[""], &run{id: id, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}})
+		})
+		It("should halt the remaining waves if a wave can not be awaited", func() {
+			err := errors.New("error")
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockSink.EXPECT().Info(gm.Any(), "starting wave")
+			mockSink.EXPECT().Error(err, "could not await wave, halting rollout")
+			mockController.EXPECT().AddPod(gm.Any()).Times(5)
+			mockController.EXPECT().AwaitNodes(id, gm.Any()).Return(0, err)
+			mockController.EXPECT().Cancel(id, "rollout halted: wave 1 could not be awaited")
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return(finished, nil)
+
+			cj.dispatch(cj.schedules[""], &run{id: id, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}})
+		})
+		It("should cancel the execution if a pod of a later wave can not be created", func() {
+			cj.cfg.JobPodTemplate = `kind: Pod{{ if eq .NodeName "a3" }}{{ lower 1 }}{{ end }}`
+			sc, _ = cj.cfg.ScheduleFor("")
+			s := cj.schedules[""]
+			gen, err := cj.acquire(s, config.ConcurrencyPolicyForbid, false)
+			Ω(err).ShouldNot(HaveOccurred())
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockSink.EXPECT().Info(gm.Any(), "starting wave").Times(2)
+			mockSink.EXPECT().Error(gm.Any(), "error creating pod from template")
+			mockController.EXPECT().AddPod(gm.Any()).Times(4)
+			mockController.EXPECT().AwaitNodes(id, gm.Any()).Return(0, nil)
+			mockController.EXPECT().Cancel(id, "a pod could not be created from the template")
+			mockController.EXPECT().AllAdded(id)
+
+			r := &run{id: id, generation: gen, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}}
+			cj.dispatch(s, r)
+			Ω(s.running).Should(BeFalse())
+		})
+		It("should not halt the rollout of a cancelled execution", func() {
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockSink.EXPECT().Info(gm.Any(), "starting wave")
+			mockSink.EXPECT().Info(gm.Any(), "execution cancelled, stopping rollout")
+			// the nodes of the remaining waves are added as cancelled
+			mockController.EXPECT().AddPod(gm.Any()).Times(5)
+			mockController.EXPECT().AwaitNodes(id, gm.Any()).Return(4, lifecycle.ErrExecutionCancelled)
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return(finished, nil)
+
+			cj.dispatch(cj.schedules[""], &run{id: id, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}})
+		})
+	})
+
+	Context("canary", func() {
+		var (
+			targets      []target.Target
+			sc           config.Schedule
+			prom         *metrics.Collector
+			mockRecorder *mockevents.MockEventRecorder
+			owner        *appsv1.Deployment
+			added        []string
+		)
+		BeforeEach(func() {
+			var err error
+			prom, err = metrics.NewPromCollector(&config.Config{Metrics: config.Metrics{Prefix: "cron_test"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			mockRecorder = mockevents.NewMockEventRecorder(mockCtrl)
+			owner = &appsv1.Deployment{}
+			cj.InjectMetrics(prom)
+			cj.InjectEventRecorder(mockRecorder)
+			cj.cfg.Owner = owner
+			cj.cfg.JobPodTemplate = "kind: Pod"
+			cj.cfg.Canary = config.Canary{Nodes: 1}
+			sc, _ = cj.cfg.ScheduleFor("")
+			targets = []target.Target{
+				{Kind: target.KindNode, Name: "node-a"},
+				{Kind: target.KindNode, Name: "node-b"},
+				{Kind: target.KindNode, Name: "node-c"},
+			}
+			added = nil
+			mockSink.EXPECT().WithValues(gm.Any()).Return(mockSink).AnyTimes()
+			mockSink.EXPECT().Info(gm.Any(), "executing job")
+			mockSink.EXPECT().Info(gm.Any(), "starting canary phase")
+			mockController.EXPECT().AddPod(gm.Any()).Times(3).DoAndReturn(func(j lifecycle.Job) error {
+				added = append(added, j.Node())
+				return nil
+			})
+			mockController.EXPECT().AllAdded(id)
+			mockController.EXPECT().Finished(id).Return(finished, nil)
+		})
+		It("should start the other nodes if the canaries succeeded", func() {
+			mockSink.EXPECT().Info(gm.Any(), "canary phase succeeded")
+			mockController.EXPECT().AwaitNodes(id, []string{"node-a"}).DoAndReturn(func(string, []string) (int, error) {
+				// the other nodes are not started before the canaries are terminated
+				Ω(added).Should(Equal([]string{"node-a"}))
+				return 0, nil
+			})
+
+			cj.dispatch(cj.schedules[""], &run{id: id, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}})
+			Ω(added).Should(Equal([]string{"node-a", "node-b", "node-c"}))
+		})
+		It("should abort the execution if a canary failed", func() {
+			mockSink.EXPECT().Info(gm.Any(), "canary phase failed, aborting execution")
+			mockController.EXPECT().AwaitNodes(id, []string{"node-a"}).Return(1, nil)
+			mockController.EXPECT().Cancel(id, "canary failed: 1 of 1 nodes failed")
+			mockRecorder.EXPECT().Eventf(owner, nil, corev1.EventTypeWarning, eventReasonCanary, eventActionCanary,
+				gm.Any(), id, 1, 1)
+
+			cj.dispatch(cj.schedules[""], &run{id: id, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}})
+			Ω(testutil.CollectAndCompare(prom, strings.NewReader(`
+				# HELP cron_test_canary_failed_total The number of executions aborted by a failed canary phase
+				# TYPE cron_test_canary_failed_total counter
+				cron_test_canary_failed_total{schedule=""} 1
+			`), "cron_test_canary_failed_total")).ShouldNot(HaveOccurred())
+		})
+		It("should not fail the canary phase of a cancelled execution", func() {
+			mockSink.EXPECT().Info(gm.Any(), "execution cancelled during canary phase")
+			mockController.EXPECT().AwaitNodes(id, []string{"node-a"}).Return(1, lifecycle.ErrExecutionCancelled)
+
+			cj.dispatch(cj.schedules[""], &run{id: id, schedule: sc, targets: targets, log: log, dispatched: map[string]bool{}})
+			Ω(added).Should(Equal([]string{"node-a", "node-b", "node-c"}))
+			Ω(testutil.CollectAndCount(prom, "cron_test_canary_failed_total")).Should(BeZero())
+		})
+	})
+
+	Context("filterTargets", func() {
+		var targets []target.Target
+		BeforeEach(func() {
+			targets = []target.Target{
+				{Kind: target.KindNode, Name: "node-a", Labels: map[string]string{"zone": "a"}},
+				{Kind: target.KindNode, Name: "node-b", Labels: map[string]string{"zone": "b"}},
+				{Kind: target.KindNode, Name: "node-c", Labels: map[string]string{"zone": "a"}},
+			}
+		})
+		It("should return all nodes without options", func() {
+			filtered, err := filterTargets(targets, lifecycle.TriggerOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(filtered).Should(HaveLen(3))
+		})
+		It("should filter by name", func() {
+			filtered, err := filterTargets(targets, lifecycle.TriggerOptions{Nodes: []string{"node-b"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(filtered).Should(HaveLen(1))
+			Ω(filtered[0].Name).Should(Equal("node-b"))
+		})
+		It("should filter by selector", func() {
+			filtered, err := filterTargets(targets, lifecycle.TriggerOptions{Selector: "zone=a"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(filtered).Should(HaveLen(2))
+		})
+		It("should filter by name and selector", func() {
+			opts := lifecycle.TriggerOptions{Nodes: []string{"node-a", "node-b"}, Selector: "zone=a"}
+			filtered, err := filterTargets(targets, opts)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(filtered).Should(HaveLen(1))
+			Ω(filtered[0].Name).Should(Equal("node-a"))
+		})
+		It("should return an error for an invalid selector", func() {
+			_, err := filterTargets(targets, lifecycle.TriggerOptions{Selector: "zone in (a"})
+			Ω(err).Should(HaveOccurred())
 		})
 	})
 
@@ -159,46 +1272,110 @@ var _ = Describe("Cron", func() {
 				pj.CreatePod()
 			})
 		})
-		It("should return the id", func() {
-			Ω(pj.ID()).Should(Equal(id))
+		It("should create the job of a retry with a new pod", func() {
+			pj.pod.Name = "test-job-node-" + id
+			pj.pod.ResourceVersion = "1"
+			pj.pod.Status.Phase = corev1.PodFailed
+
+			retry, ok := pj.Retry(1).(*podJob)
+			Ω(ok).Should(BeTrue())
+			Ω(retry.ID()).Should(Equal(id))
+			Ω(retry.Node()).Should(Equal(nodeName))
+			Ω(retry.pod.Name).Should(Equal("test-job-node-" + id + "-retry-1"))
+			Ω(retry.pod.ResourceVersion).Should(BeEmpty())
+			Ω(retry.pod.Status.Phase).Should(BeEmpty())
+			Ω(retry.pod.Labels).Should(HaveKeyWithValue(controller.LabelAttempt, "1"))
+			Ω(pj.pod.Labels).ShouldNot(HaveKey(controller.LabelAttempt))
+
+			retry, ok = retry.Retry(2).(*podJob)
+			Ω(ok).Should(BeTrue())
+			Ω(retry.pod.Name).Should(Equal("test-job-node-" + id + "-retry-2"))
 		})
-		It("should return the nodeName", func() {
-			Ω(pj.Node()).Should(Equal(nodeName))
+		It("should render the pod of a retry for the attempt", func() {
+			cj.cfg.JobPodTemplate = "kind: Pod\nmetadata:\n  annotations:\n    attempt: '{{ .Attempt }}'"
+			r := &run{id: id, schedule: cj.cfg.AllSchedules()[0]}
+			pj, err := cj.newPodJob(r, target.Target{Kind: target.KindNode, Name: "node-a"}, 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pj.pod.Annotations).Should(HaveKeyWithValue("attempt", "0"))
+
+			retry, ok := pj.Retry(1).(*podJob)
+			Ω(ok).Should(BeTrue())
+			Ω(retry.pod.Name).Should(Equal(pj.pod.Name + "-retry-1"))
+			Ω(retry.pod.Annotations).Should(HaveKeyWithValue("attempt", "1"))
+			Ω(retry.pod.Labels).Should(HaveKeyWithValue(controller.LabelAttempt, "1"))
+
+			retry, ok = retry.Retry(2).(*podJob)
+			Ω(ok).Should(BeTrue())
+			Ω(retry.pod.Name).Should(Equal(pj.pod.Name + "-retry-2"))
+			Ω(retry.pod.Annotations).Should(HaveKeyWithValue("attempt", "2"))
 		})
-	})
+		It("should copy the pod of a retry that can not be rendered", func() {
+			pj.pod.Name = "test-job-node-" + id
+			pj.create = func(int) (*podJob, error) {
+				return nil, errors.New("some error")
+			}
+			mockSink.EXPECT().Error(gm.Any(), "error creating pod of retry from template", "node", nodeName)
 
-	Context("isUsable", func() {
-		var (
-			node                  corev1.Node
-			runOnUnscheduledNodes bool
-		)
-		BeforeEach(func() {
-			node = corev1.Node{
-				Spec:   corev1.NodeSpec{},
-				Status: corev1.NodeStatus{},
+			retry, ok := pj.Retry(1).(*podJob)
+			Ω(ok).Should(BeTrue())
+			Ω(retry.pod.Name).Should(Equal("test-job-node-" + id + "-retry-1"))
+		})
+		It("should create the batch job in job mode", func() {
+			pj.pod = nil
+			pj.batch = &batchv1.Job{}
+			mockSink.EXPECT().Info(gm.Any(), "create pod", "node", nodeName)
+			mockClient.EXPECT().Create(gm.Any(), pj.batch)
+
+			pj.CreatePod()
+		})
+		It("should create the job of a retry with a new batch job", func() {
+			pj.pod = nil
+			pj.batch = &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job-node-" + id, ResourceVersion: "1", UID: "uid"},
+				Spec: batchv1.JobSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{batchv1.ControllerUidLabel: "uid"}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+							controller.LabelExecutionID: id,
+							batchv1.ControllerUidLabel:  "uid",
+							batchv1.JobNameLabel:        "test-job-node-" + id,
+						}},
+					},
+				},
+				Status: batchv1.JobStatus{Failed: 1},
 			}
+
+			retry, ok := pj.Retry(1).(*podJob)
+			Ω(ok).Should(BeTrue())
+			Ω(retry.pod).Should(BeNil())
+			Ω(retry.batch.Name).Should(Equal("test-job-node-" + id + "-retry-1"))
+			Ω(retry.batch.ResourceVersion).Should(BeEmpty())
+			Ω(retry.batch.UID).Should(BeEmpty())
+			Ω(retry.batch.Status.Failed).Should(BeZero())
+			Ω(retry.batch.Spec.Selector).Should(BeNil())
+			Ω(retry.batch.Labels).Should(HaveKeyWithValue(controller.LabelAttempt, "1"))
+			Ω(retry.batch.Spec.Template.Labels).Should(Equal(map[string]string{
+				controller.LabelExecutionID: id,
+				controller.LabelAttempt:     "1",
+			}))
+			Ω(pj.batch.Spec.Template.Labels).Should(HaveKey(batchv1.ControllerUidLabel))
+		})
+		It("should shorten the name of the batch job of a retry", func() {
+			pj.pod = nil
+			pj.batch = &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: job.BatchName(
+				"test-job-storage-node-with-a-very-long-name-in-the-cluster-" + id,
+			)}}
+
+			retry, ok := pj.Retry(1).(*podJob)
+			Ω(ok).Should(BeTrue())
+			Ω(len(retry.batch.Name)).Should(BeNumerically("<=", 63))
+			Ω(retry.batch.Name).ShouldNot(Equal(pj.batch.Name))
 		})
-		It("should return false if unschedulable", func() {
-			node.Spec.Unschedulable = true
-			runOnUnscheduledNodes = false
-			Ω(isUsable(node, runOnUnscheduledNodes)).Should(BeFalse())
-		})
-		It("should return true if node ready", func() {
-			node.Spec.Unschedulable = false
-			node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
-			runOnUnscheduledNodes = false
-			Ω(isUsable(node, runOnUnscheduledNodes)).Should(BeTrue())
-		})
-		It("should return false if node not ready", func() {
-			node.Spec.Unschedulable = false
-			node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}
-			runOnUnscheduledNodes = false
-			Ω(isUsable(node, runOnUnscheduledNodes)).Should(BeFalse())
-		})
-		It("should return false if no conditions are set", func() {
-			node.Spec.Unschedulable = false
-			runOnUnscheduledNodes = false
-			Ω(isUsable(node, runOnUnscheduledNodes)).Should(BeFalse())
+		It("should return the id", func() {
+			Ω(pj.ID()).Should(Equal(id))
+		})
+		It("should return the nodeName", func() {
+			Ω(pj.Node()).Should(Equal(nodeName))
 		})
 	})
 })
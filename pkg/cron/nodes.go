@@ -0,0 +1,238 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/bakito/batch-job-controller/pkg/lifecycle"
+	"github.com/bakito/batch-job-controller/pkg/target"
+)
+
+// SetupNodeWatcher setup the watcher of nodes that become ready between scheduled executions, if it is enabled.
+func SetupNodeWatcher(mgr ctrl.Manager, r manager.Runnable) error {
+	j, ok := r.(*cronJob)
+	if !ok {
+		return fmt.Errorf("unexpected cron job %T", r)
+	}
+	if !j.cfg.NodeWatcher.Enabled {
+		return nil
+	}
+	log.WithValues("cooldown", j.cfg.NodeWatcher.Cooldown.Duration.String()).Info("watching nodes")
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("node-watcher").
+		For(&corev1.Node{}).
+		WithEventFilter(&nodePredicate{since: time.Now()}).
+		Complete(&nodeWatcher{job: j})
+}
+
+// nodeWatcher reconciles nodes that became ready.
+type nodeWatcher struct {
+	job *cronJob
+}
+
+// Reconcile start the jobs of a ready node.
+func (w *nodeWatcher) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	node := &corev1.Node{}
+	if err := w.job.client.Get(ctx, req.NamespacedName, node); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	w.job.nodeReady(*node)
+	return ctrl.Result{}, nil
+}
+
+// nodeReady start the jobs of a node that became ready for all schedules the node matches.
+// If an execution of the schedule is running, the node is added to it, otherwise a partial execution of the node is
+// started. The partial execution does not hold the schedule, scheduled and on demand executions are not rejected or
+// queued while it is running.
+func (j *cronJob) nodeReady(node corev1.Node) {
+	if !target.IsUsable(node, j.cfg.RunOnUnscheduledNodes) {
+		return
+	}
+	for _, sc := range j.cfg.AllSchedules() {
+		if !sc.Targets.IsNodes() || !sc.MatchesNode(node) {
+			continue
+		}
+		s := j.schedules[sc.Name]
+		l := log.WithValues("schedule", s.name, "node", node.Name)
+		if !j.coolDown(s.name, node.Name) {
+			l.Info("node is cooling down")
+			continue
+		}
+		if j.addNode(s, target.FromNode(node)) {
+			l.Info("adding ready node to running execution")
+		} else {
+			l.Info("starting execution of ready node")
+			go j.startNode(s, node)
+		}
+	}
+}
+
+// coolDown check if a job of the node may be started. The start is recorded if it may.
+func (j *cronJob) coolDown(scheduleName, nodeName string) bool {
+	j.cooldownMux.Lock()
+	defer j.cooldownMux.Unlock()
+
+	now := time.Now()
+	cooldown := j.cfg.NodeWatcher.Cooldown.Duration
+	for key, t := range j.cooldowns {
+		if now.Sub(t) >= cooldown {
+			delete(j.cooldowns, key)
+		}
+	}
+	key := scheduleName + "/" + nodeName
+	if _, ok := j.cooldowns[key]; ok {
+		return false
+	}
+	j.cooldowns[key] = now
+	return true
+}
+
+// addNode add a ready node to a running execution of the schedule. While the jobs of the execution holding the
+// schedule are dispatched, the node is dispatched with them, afterwards its job is added to the execution directly.
+// Otherwise, the job is added to the running execution of ready nodes. Returns false if no execution is running or it
+// finished meanwhile, an execution of the node is to be started then.
+func (j *cronJob) addNode(s *schedule, node target.Target) bool {
+	s.mux.Lock()
+	if s.running && s.dispatched == nil {
+		s.pending = append(s.pending, node)
+		s.mux.Unlock()
+		return true
+	}
+	runs := []*run{s.dispatched, s.nodeRun}
+	s.mux.Unlock()
+
+	for _, r := range runs {
+		if r != nil && j.addToDispatched(s, r, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// addToDispatched add the job of a ready node to an execution whose jobs are all dispatched. The job is created
+// holding the lock of the schedule, but added after unlocking, since adding blocks while the workers are busy.
+// Returns false if the execution is finished.
+func (j *cronJob) addToDispatched(s *schedule, r *run, node target.Target) bool {
+	s.mux.Lock()
+	if r.dispatched[node.ID()] {
+		// the job of the node is part of the execution already
+		s.mux.Unlock()
+		return true
+	}
+	pj, err := j.newPodJob(r, node, 0)
+	if err != nil {
+		s.mux.Unlock()
+		r.log.Error(err, "error creating pod from template")
+		return true
+	}
+	r.dispatched[node.ID()] = true
+	s.mux.Unlock()
+
+	if err := j.controller.AddPod(pj); err != nil {
+		s.mux.Lock()
+		delete(r.dispatched, node.ID())
+		s.mux.Unlock()
+		return false
+	}
+	return true
+}
+
+// startNode start a partial execution of a single ready node. The job pods of other nodes of previous executions
+// are kept.
+func (j *cronJob) startNode(s *schedule, node corev1.Node) {
+	l := log.WithValues("schedule", s.name, "node", node.Name)
+	loc, err := j.cfg.Location()
+	if err != nil {
+		l.Error(err, "could not start execution of ready node")
+		return
+	}
+	if w, end, ok := j.cfg.MaintenanceWindowAt(time.Now().In(loc)); ok {
+		l.WithValues("window", w.Name, "end", end.Format(time.RFC3339)).
+			Info("not starting execution of ready node within maintenance window")
+		return
+	}
+	sc, ok := j.cfg.ScheduleFor(s.name)
+	if !ok {
+		return
+	}
+	r, err := j.prepareRun(sc, lifecycle.TriggerOptions{Nodes: []string{node.Name}}, true)
+	if err != nil {
+		return
+	}
+	j.dispatchNode(s, r)
+}
+
+// dispatchNode dispatch the jobs of an execution of ready nodes. Nodes that become ready afterwards are added to the
+// execution until it is finished.
+func (j *cronJob) dispatchNode(s *schedule, r *run) {
+	r.log.Info("executing job")
+	if !j.addPods(r, r.targets) {
+		j.abort(r)
+		return
+	}
+	s.mux.Lock()
+	s.nodeRun = r
+	s.mux.Unlock()
+
+	_ = j.controller.AllAdded(r.id)
+
+	done, err := j.controller.Finished(r.id)
+	if err != nil {
+		r.log.Error(err, "could not await execution")
+		s.nodeFinished(r)
+		return
+	}
+	go func() {
+		<-done
+		s.nodeFinished(r)
+	}()
+}
+
+// nodeFinished stop adding ready nodes to the execution of ready nodes of the given run.
+func (s *schedule) nodeFinished(r *run) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.nodeRun == r {
+		s.nodeRun = nil
+	}
+}
+
+// nodePredicate passes nodes created after the watcher was started and nodes that may have become ready.
+type nodePredicate struct {
+	since time.Time
+}
+
+func (p *nodePredicate) Create(e event.CreateEvent) bool {
+	// existing nodes are covered by the scheduled executions
+	return e.Object.GetCreationTimestamp().After(p.since)
+}
+
+func (*nodePredicate) Update(e event.UpdateEvent) bool {
+	oldNode, ok := e.ObjectOld.(*corev1.Node)
+	if !ok {
+		return false
+	}
+	newNode, ok := e.ObjectNew.(*corev1.Node)
+	if !ok {
+		return false
+	}
+	return oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable || target.IsReady(*oldNode) != target.IsReady(*newNode)
+}
+
+func (*nodePredicate) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (*nodePredicate) Generic(event.GenericEvent) bool {
+	return false
+}
@@ -0,0 +1,180 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/bakito/batch-job-controller/pkg/lifecycle"
+)
+
+// ExecutionResponse response of an execution request.
+type ExecutionResponse struct {
+	ExecutionID string `json:"executionID"`
+}
+
+// CancelRequest request to cancel an execution.
+type CancelRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// ExecutionsResponse response listing the known executions.
+type ExecutionsResponse struct {
+	// Progress the overall progress of the current execution
+	Progress   string                       `json:"progress"`
+	Executions []*lifecycle.ExecutionStatus `json:"executions"`
+}
+
+func (s *PostServer) getExecutions(ctx *gin.Context) {
+	executions := s.Controller.Executions()
+	if executions == nil {
+		executions = []*lifecycle.ExecutionStatus{}
+	}
+	ctx.JSON(http.StatusOK, &ExecutionsResponse{
+		Progress:   s.Controller.Progress(),
+		Executions: executions,
+	})
+}
+
+func (s *PostServer) getExecution(ctx *gin.Context) {
+	executionID := ctx.Param("executionID")
+	status, err := s.Controller.Execution(executionID)
+	if err != nil {
+		if errors.Is(err, &lifecycle.ExecutionIDNotFoundError{}) {
+			ctx.String(http.StatusNotFound, err.Error())
+		} else {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			s.Log.WithValues("path", ctx.FullPath(), "id", executionID).Error(err, "error getting execution status")
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+func (s *PostServer) postExecution(ctx *gin.Context) {
+	apiLog := s.Log.WithValues("path", ctx.FullPath())
+
+	body, err := ctx.GetRawData()
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		apiLog.Error(err, "error reading body")
+		return
+	}
+
+	opts := lifecycle.TriggerOptions{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &opts); err != nil {
+			ctx.String(http.StatusBadRequest, "error decoding trigger options: "+err.Error())
+			apiLog.Error(err, "error decoding trigger options")
+			return
+		}
+	}
+	if _, err := labels.Parse(opts.Selector); err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		apiLog.Error(err, "invalid selector")
+		return
+	}
+
+	if s.Trigger == nil {
+		ctx.String(http.StatusServiceUnavailable, "trigger is not available")
+		return
+	}
+
+	id, err := s.Trigger.Trigger(opts)
+	if err != nil {
+		triggerError(ctx, err)
+		apiLog.Error(err, "error triggering execution")
+		return
+	}
+
+	apiLog.WithValues("id", id, "nodes", opts.Nodes, "selector", opts.Selector).Info("execution triggered")
+	ctx.JSON(http.StatusCreated, &ExecutionResponse{ExecutionID: id})
+}
+
+func (s *PostServer) postRerun(ctx *gin.Context) {
+	executionID := ctx.Param("executionID")
+	apiLog := s.Log.WithValues("path", ctx.FullPath(), "id", executionID)
+
+	if s.Trigger == nil {
+		ctx.String(http.StatusServiceUnavailable, "trigger is not available")
+		return
+	}
+
+	nodes, err := s.Controller.FailedNodes(executionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, &lifecycle.ExecutionIDNotFoundError{}):
+			ctx.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, lifecycle.ErrExecutionNotFinished):
+			ctx.String(http.StatusConflict, err.Error())
+		case errors.Is(err, lifecycle.ErrNoFailedNodes):
+			ctx.String(http.StatusBadRequest, err.Error())
+		default:
+			ctx.String(http.StatusInternalServerError, err.Error())
+		}
+		apiLog.Error(err, "error getting failed nodes")
+		return
+	}
+
+	id, err := s.Trigger.Trigger(lifecycle.TriggerOptions{Nodes: nodes, RerunOf: executionID})
+	if err != nil {
+		triggerError(ctx, err)
+		apiLog.Error(err, "error triggering rerun")
+		return
+	}
+
+	apiLog.WithValues("rerun", id, "nodes", nodes).Info("rerun triggered")
+	ctx.JSON(http.StatusCreated, &ExecutionResponse{ExecutionID: id})
+}
+
+// triggerError respond with the status matching the error of a trigger.
+func triggerError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, lifecycle.ErrExecutionRunning):
+		ctx.String(http.StatusConflict, err.Error())
+	case errors.Is(err, lifecycle.ErrNoMatchingNodes), errors.Is(err, lifecycle.ErrUnknownSchedule):
+		ctx.String(http.StatusBadRequest, err.Error())
+	default:
+		ctx.String(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (s *PostServer) postCancel(ctx *gin.Context) {
+	executionID := ctx.Param("executionID")
+	apiLog := s.Log.WithValues("path", ctx.FullPath(), "id", executionID)
+
+	body, err := ctx.GetRawData()
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		apiLog.Error(err, "error reading body")
+		return
+	}
+
+	req := &CancelRequest{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, req); err != nil {
+			ctx.String(http.StatusBadRequest, "error decoding cancel request: "+err.Error())
+			apiLog.Error(err, "error decoding cancel request")
+			return
+		}
+	}
+
+	if err := s.Controller.Cancel(executionID, req.Reason); err != nil {
+		switch {
+		case errors.Is(err, &lifecycle.ExecutionIDNotFoundError{}):
+			ctx.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, lifecycle.ErrExecutionFinished):
+			ctx.String(http.StatusConflict, err.Error())
+		default:
+			ctx.String(http.StatusInternalServerError, err.Error())
+		}
+		apiLog.Error(err, "error cancelling execution")
+		return
+	}
+
+	apiLog.WithValues("reason", req.Reason).Info("execution cancelled")
+	ctx.JSON(http.StatusOK, &ExecutionResponse{ExecutionID: executionID})
+}
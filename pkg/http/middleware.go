@@ -1,13 +1,17 @@
 package http
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 const (
 	errorMiddlewareNotAcceptable = "node / execution ID not allowed"
+	errorAPIDisabled             = "api is disabled, no token is configured"
+	errorUnauthorized            = "invalid or missing bearer token"
 )
 
 func (s *PostServer) middleware(ctx *gin.Context) {
@@ -20,3 +24,22 @@ func (s *PostServer) middleware(ctx *gin.Context) {
 	}
 	ctx.Next()
 }
+
+// authenticate api requests with the configured bearer token.
+func (s *PostServer) authenticate(ctx *gin.Context) {
+	if s.Config.APIToken == "" {
+		if !s.Config.DevMode {
+			ctx.String(http.StatusForbidden, errorAPIDisabled)
+			ctx.Abort()
+			return
+		}
+	} else {
+		token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.APIToken)) != 1 {
+			ctx.String(http.StatusUnauthorized, errorUnauthorized)
+			ctx.Abort()
+			return
+		}
+	}
+	ctx.Next()
+}
@@ -30,12 +30,14 @@ func (s *PostServer) saveFormFilesCallback(
 		return err
 	}
 
-	err := ctx.SaveUploadedFile(file, s.Config.ReportFileName(executionID, fmt.Sprintf("%s-%s", node, file.Filename)))
+	fileName := s.Config.ReportFileName(executionID, fmt.Sprintf("%s-%s", node, file.Filename))
+	err := ctx.SaveUploadedFile(file, fileName)
 	if err != nil {
 		ctx.String(http.StatusInternalServerError, err.Error())
 		postLog.Error(err, "error saving file")
 		return err
 	}
+	s.Controller.FileReceived(executionID, node, filepath.Base(fileName))
 	return nil
 }
 
@@ -58,6 +60,7 @@ func (s *PostServer) saveBodyFileCallback(
 		postLog.Error(err, "error receiving file")
 		return err
 	}
+	s.Controller.FileReceived(executionID, node, filepath.Base(fileName))
 	return nil
 }
 
@@ -29,6 +29,17 @@ const (
 
 	// FileName query parameter name.
 	FileName = "name"
+
+	// APIBasePath api path.
+	APIBasePath = "/api/v1"
+	// APIExecutionsPath executions sub path.
+	APIExecutionsPath = "/executions"
+	// APIExecutionPath path of a single execution.
+	APIExecutionPath = APIExecutionsPath + "/:executionID"
+	// APICancelSubPath cancel sub path of an execution.
+	APICancelSubPath = "/cancel"
+	// APIRerunSubPath rerun sub path of an execution.
+	APIRerunSubPath = "/rerun"
 )
 
 // GenericAPIServer prepare the generic api server.
@@ -60,6 +71,23 @@ func GenericAPIServer(port int, cfg *config.Config) manager.Runnable {
 		"event", fmt.Sprintf("%s%s", CallbackBasePath, CallbackBaseEventSubPath),
 	)
 
+	api := r.Group(APIBasePath)
+	api.Use(gin.Recovery(), s.authenticate)
+	api.GET(APIExecutionsPath, s.getExecutions)
+	api.GET(APIExecutionPath, s.getExecution)
+	api.POST(APIExecutionsPath, s.postExecution)
+	api.POST(APIExecutionPath+APICancelSubPath, s.postCancel)
+	api.POST(APIExecutionPath+APIRerunSubPath, s.postRerun)
+
+	s.Log.Info("starting api",
+		"port", port,
+		"executions", fmt.Sprintf("GET %s%s", APIBasePath, APIExecutionsPath),
+		"execution", fmt.Sprintf("GET %s%s", APIBasePath, APIExecutionPath),
+		"trigger", fmt.Sprintf("POST %s%s", APIBasePath, APIExecutionsPath),
+		"cancel", fmt.Sprintf("POST %s%s%s", APIBasePath, APIExecutionPath, APICancelSubPath),
+		"rerun", fmt.Sprintf("POST %s%s%s", APIBasePath, APIExecutionPath, APIRerunSubPath),
+	)
+
 	SetupProfiling(r)
 
 	return s
@@ -87,6 +115,7 @@ type PostServer struct {
 	Config        *config.Config
 	EventRecorder events.EventRecorder
 	Client        client.Reader
+	Trigger       lifecycle.Trigger
 }
 
 // InjectEventRecorder inject the event recorder.
@@ -99,6 +128,11 @@ func (s *PostServer) InjectController(c lifecycle.Controller) {
 	s.Controller = c
 }
 
+// InjectTrigger inject the execution trigger.
+func (s *PostServer) InjectTrigger(t lifecycle.Trigger) {
+	s.Trigger = t
+}
+
 // InjectReader inject the client reader.
 func (s *PostServer) InjectReader(reader client.Reader) {
 	s.Client = reader
@@ -11,17 +11,20 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	gm "go.uber.org/mock/gomock"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/bakito/batch-job-controller/pkg/config"
 	"github.com/bakito/batch-job-controller/pkg/inject"
+	"github.com/bakito/batch-job-controller/pkg/lifecycle"
 	mockclient "github.com/bakito/batch-job-controller/pkg/mocks/client"
 	mockevents "github.com/bakito/batch-job-controller/pkg/mocks/events"
 	mocklifecycle "github.com/bakito/batch-job-controller/pkg/mocks/lifecycle"
@@ -45,6 +48,7 @@ var (
 	_ inject.Config        = &PostServer{}
 	_ inject.Controller    = &PostServer{}
 	_ inject.Reader        = &PostServer{}
+	_ inject.Trigger       = &PostServer{}
 )
 
 var _ = Describe("HTTP", func() {
@@ -205,6 +209,10 @@ var _ = Describe("HTTP", func() {
 
 				mockSink.EXPECT().WithValues("name", gm.Any(), "path", gm.Any(), "length", gm.Any()).Return(mockSink)
 				mockSink.EXPECT().Info(gm.Any(), "received 1 file")
+				mockController.EXPECT().FileReceived(executionID, node, gm.Any()).
+					Do(func(_, _, name string) {
+						Ω(name).Should(HavePrefix(node + "-"))
+					})
 				DeferCleanup(func() error {
 					Ω(rr.Code).Should(Equal(http.StatusOK))
 
@@ -264,6 +272,8 @@ var _ = Describe("HTTP", func() {
 			It("upload 2 files", func() {
 				mockSink.EXPECT().WithValues("names", gm.Any()).Return(mockSink)
 				mockSink.EXPECT().Info(gm.Any(), "received 2 file(s)")
+				mockController.EXPECT().FileReceived(executionID, node, node+"-a")
+				mockController.EXPECT().FileReceived(executionID, node, node+"-b")
 
 				body := &bytes.Buffer{}
 				writer := multipart.NewWriter(body)
@@ -376,6 +386,301 @@ var _ = Describe("HTTP", func() {
 		})
 	})
 
+	Context("postExecution", func() {
+		var (
+			mockTrigger *mocklifecycle.MockTrigger
+			path        string
+		)
+		BeforeEach(func() {
+			mockTrigger = mocklifecycle.NewMockTrigger(mockCtrl)
+			s.InjectTrigger(mockTrigger)
+			cfg.APIToken = "secret"
+			path = APIBasePath + APIExecutionsPath
+			api := router.Group(APIBasePath)
+			api.Use(s.authenticate)
+			api.POST(APIExecutionsPath, s.postExecution)
+			mockSink.EXPECT().WithValues("path", path).Return(mockSink).AnyTimes()
+		})
+		It("should trigger an execution", func() {
+			mockTrigger.EXPECT().Trigger(lifecycle.TriggerOptions{Nodes: []string{"node-a"}}).Return(executionID, nil)
+			mockSink.EXPECT().WithValues("id", executionID, "nodes", []string{"node-a"}, "selector", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "execution triggered")
+
+			req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(`{"nodes": ["node-a"]}`))
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusCreated))
+			Ω(rr.Body.String()).Should(MatchJSON(fmt.Sprintf(`{"executionID": %q}`, executionID)))
+		})
+		It("should trigger an execution without options", func() {
+			mockTrigger.EXPECT().Trigger(lifecycle.TriggerOptions{}).Return(executionID, nil)
+			mockSink.EXPECT().WithValues("id", executionID, "nodes", gm.Nil(), "selector", "").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "execution triggered")
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusCreated))
+		})
+		It("should return a conflict if an execution is running", func() {
+			mockTrigger.EXPECT().Trigger(gm.Any()).Return("", lifecycle.ErrExecutionRunning)
+			mockSink.EXPECT().Error(lifecycle.ErrExecutionRunning, "error triggering execution")
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusConflict))
+		})
+		It("should reject an invalid selector", func() {
+			mockSink.EXPECT().Error(gm.Any(), "invalid selector")
+
+			req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(`{"selector": "a in (b"}`))
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusBadRequest))
+		})
+		It("should reject an invalid token", func() {
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer foo")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusUnauthorized))
+		})
+		It("should be disabled if no token is configured", func() {
+			cfg.APIToken = ""
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusForbidden))
+			Ω(rr.Body.String()).Should(Equal(errorAPIDisabled))
+		})
+	})
+
+	Context("getExecutions", func() {
+		BeforeEach(func() {
+			cfg.APIToken = "secret"
+			api := router.Group(APIBasePath)
+			api.Use(s.authenticate)
+			api.GET(APIExecutionsPath, s.getExecutions)
+			api.GET(APIExecutionPath, s.getExecution)
+		})
+		It("should list the executions", func() {
+			started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			mockController.EXPECT().Progress().Return("50%")
+			mockController.EXPECT().Executions().Return([]*lifecycle.ExecutionStatus{
+				{ID: executionID, Started: started, Progress: "50%", Pods: 2, Terminated: 1},
+			})
+
+			req, err := http.NewRequest(http.MethodGet, APIBasePath+APIExecutionsPath, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusOK))
+			Ω(rr.Body.String()).Should(MatchJSON(fmt.Sprintf(`{
+  "progress": "50%%",
+  "executions": [
+    {"id": %q, "started": "2024-01-02T03:04:05Z", "progress": "50%%", "pods": 2, "terminated": 1}
+  ]
+}`, executionID)))
+		})
+		It("should return an empty list", func() {
+			mockController.EXPECT().Progress().Return("0%")
+			mockController.EXPECT().Executions().Return(nil)
+
+			req, err := http.NewRequest(http.MethodGet, APIBasePath+APIExecutionsPath, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusOK))
+			Ω(rr.Body.String()).Should(MatchJSON(`{"progress": "0%", "executions": []}`))
+		})
+		It("should return the status of an execution", func() {
+			mockController.EXPECT().Execution(executionID).Return(&lifecycle.ExecutionStatus{
+				ID:       executionID,
+				Progress: "0%",
+				Pods:     1,
+				Nodes: map[string]*lifecycle.NodeStatus{
+					node: {Status: "Started", Duration: &metav1.Duration{Duration: time.Minute}},
+				},
+			}, nil)
+
+			req, err := http.NewRequest(http.MethodGet, APIBasePath+APIExecutionsPath+"/"+executionID, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusOK))
+			Ω(rr.Body.String()).Should(ContainSubstring(fmt.Sprintf(`"nodes":{%q:{"status":"Started","duration":"1m0s"}}`, node)))
+		})
+		It("should return not found for an unknown execution", func() {
+			mockController.EXPECT().Execution(executionID).Return(nil, &lifecycle.ExecutionIDNotFoundError{Err: errors.New("not found")})
+
+			req, err := http.NewRequest(http.MethodGet, APIBasePath+APIExecutionsPath+"/"+executionID, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("postCancel", func() {
+		var path string
+		BeforeEach(func() {
+			cfg.APIToken = "secret"
+			path = fmt.Sprintf("%s%s/%s%s", APIBasePath, APIExecutionsPath, executionID, APICancelSubPath)
+			api := router.Group(APIBasePath)
+			api.Use(s.authenticate)
+			api.POST(APIExecutionPath+APICancelSubPath, s.postCancel)
+			mockSink.EXPECT().WithValues("path", APIBasePath+APIExecutionPath+APICancelSubPath, "id", executionID).Return(mockSink)
+		})
+		It("should cancel the execution", func() {
+			mockController.EXPECT().Cancel(executionID, "maintenance")
+			mockSink.EXPECT().WithValues("reason", "maintenance").Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "execution cancelled")
+
+			req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(`{"reason": "maintenance"}`))
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusOK))
+			Ω(rr.Body.String()).Should(MatchJSON(fmt.Sprintf(`{"executionID": %q}`, executionID)))
+		})
+		It("should return not found for an unknown execution", func() {
+			err := &lifecycle.ExecutionIDNotFoundError{Err: errors.New("not found")}
+			mockController.EXPECT().Cancel(executionID, "").Return(err)
+			mockSink.EXPECT().Error(err, "error cancelling execution")
+
+			req, err2 := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err2).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusNotFound))
+		})
+		It("should return conflict for a finished execution", func() {
+			mockController.EXPECT().Cancel(executionID, "").Return(lifecycle.ErrExecutionFinished)
+			mockSink.EXPECT().Error(lifecycle.ErrExecutionFinished, "error cancelling execution")
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusConflict))
+		})
+	})
+
+	Context("postRerun", func() {
+		var (
+			mockTrigger *mocklifecycle.MockTrigger
+			path        string
+		)
+		BeforeEach(func() {
+			mockTrigger = mocklifecycle.NewMockTrigger(mockCtrl)
+			s.InjectTrigger(mockTrigger)
+			cfg.APIToken = "secret"
+			path = fmt.Sprintf("%s%s/%s%s", APIBasePath, APIExecutionsPath, executionID, APIRerunSubPath)
+			api := router.Group(APIBasePath)
+			api.Use(s.authenticate)
+			api.POST(APIExecutionPath+APIRerunSubPath, s.postRerun)
+			mockSink.EXPECT().WithValues("path", APIBasePath+APIExecutionPath+APIRerunSubPath, "id", executionID).Return(mockSink)
+		})
+		It("should rerun the failed nodes", func() {
+			nodes := []string{"node-a", "node-b"}
+			mockController.EXPECT().FailedNodes(executionID).Return(nodes, nil)
+			mockTrigger.EXPECT().Trigger(lifecycle.TriggerOptions{Nodes: nodes, RerunOf: executionID}).Return("rerun-id", nil)
+			mockSink.EXPECT().WithValues("rerun", "rerun-id", "nodes", nodes).Return(mockSink)
+			mockSink.EXPECT().Info(gm.Any(), "rerun triggered")
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusCreated))
+			Ω(rr.Body.String()).Should(MatchJSON(`{"executionID": "rerun-id"}`))
+		})
+		It("should return a conflict if the execution is not finished", func() {
+			mockController.EXPECT().FailedNodes(executionID).Return(nil, lifecycle.ErrExecutionNotFinished)
+			mockSink.EXPECT().Error(lifecycle.ErrExecutionNotFinished, "error getting failed nodes")
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusConflict))
+		})
+		It("should return a bad request if there are no failed nodes", func() {
+			mockController.EXPECT().FailedNodes(executionID).Return(nil, lifecycle.ErrNoFailedNodes)
+			mockSink.EXPECT().Error(lifecycle.ErrNoFailedNodes, "error getting failed nodes")
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusBadRequest))
+		})
+		It("should return not found for an unknown execution", func() {
+			err := &lifecycle.ExecutionIDNotFoundError{Err: errors.New("not found")}
+			mockController.EXPECT().FailedNodes(executionID).Return(nil, err)
+			mockSink.EXPECT().Error(err, "error getting failed nodes")
+
+			req, err2 := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err2).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusNotFound))
+		})
+		It("should return a conflict if an execution is running", func() {
+			mockController.EXPECT().FailedNodes(executionID).Return([]string{"node-a"}, nil)
+			mockTrigger.EXPECT().Trigger(gm.Any()).Return("", lifecycle.ErrExecutionRunning)
+			mockSink.EXPECT().Error(lifecycle.ErrExecutionRunning, "error triggering rerun")
+
+			req, err := http.NewRequest(http.MethodPost, path, http.NoBody)
+			Ω(err).ShouldNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer secret")
+
+			router.ServeHTTP(rr, req)
+
+			Ω(rr.Code).Should(Equal(http.StatusConflict))
+		})
+	})
+
 	Context("StaticFileServer", func() {
 		It("returns a file server", func() {
 			cfg.ReportDirectory = "path"
@@ -7,6 +7,7 @@ import (
 
 	"github.com/bakito/batch-job-controller/pkg/config"
 	"github.com/bakito/batch-job-controller/pkg/lifecycle"
+	"github.com/bakito/batch-job-controller/pkg/metrics"
 )
 
 // injects from "sigs.k8s.io/controller-runtime/pkg/runtime/inject" are set by the manager
@@ -21,6 +22,16 @@ type Controller interface {
 	InjectController(c lifecycle.Controller)
 }
 
+// Trigger inject the execution trigger.
+type Trigger interface {
+	InjectTrigger(t lifecycle.Trigger)
+}
+
+// Metrics inject the metrics collector.
+type Metrics interface {
+	InjectMetrics(m *metrics.Collector)
+}
+
 // Reader inject the api reader.
 type Reader interface {
 	InjectReader(c client.Reader)
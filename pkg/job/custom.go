@@ -8,6 +8,6 @@ import (
 
 // CustomPodEnv interface.
 type CustomPodEnv interface {
-	// ExtendEnv extend the env for the job pod
+	// ExtendEnv extend the env for the job pod, nodeName is the identity of the target of the job
 	ExtendEnv(cfg *config.Config, nodeName string, id string, serviceIP string, containers corev1.Container) []corev1.EnvVar
 }
@@ -0,0 +1,160 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ghodss/yaml"
+)
+
+// funcMap the helper functions of the pod templates. The functions are named and called like their sprig
+// counterparts, the value of a pipeline is the last argument. The functions have no access to the environment or
+// the file system of the controller.
+func funcMap() template.FuncMap {
+	return template.FuncMap{
+		"default":    defaultValue,
+		"empty":      empty,
+		"coalesce":   coalesce,
+		"quote":      quote,
+		"squote":     squote,
+		"toYaml":     toYaml,
+		"toJson":     toJSON,
+		"indent":     indent,
+		"nindent":    nindent,
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"dict":       dict,
+		"get":        get,
+		"hasKey":     hasKey,
+	}
+}
+
+// defaultValue get the value, or the default if the value is empty.
+func defaultValue(def, value any) any {
+	if empty(value) {
+		return def
+	}
+	return value
+}
+
+// empty returns true if the value is nil or the zero value of its type, or an empty map, slice or string.
+func empty(value any) bool {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// coalesce get the first value that is not empty, nil if all are empty.
+func coalesce(values ...any) any {
+	for _, v := range values {
+		if !empty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func quote(values ...any) string {
+	q := make([]string, len(values))
+	for i, v := range values {
+		q[i] = strconv.Quote(toString(v))
+	}
+	return strings.Join(q, " ")
+}
+
+func squote(values ...any) string {
+	q := make([]string, len(values))
+	for i, v := range values {
+		q[i] = "'" + strings.ReplaceAll(toString(v), "'", "''") + "'"
+	}
+	return strings.Join(q, " ")
+}
+
+func toString(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// toYaml render the value as yaml without the trailing newline.
+func toYaml(value any) (string, error) {
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+func toJSON(value any) (string, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// indent indent each line of the string by the number of spaces.
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// nindent indent the string like indent and prepend a newline.
+func nindent(spaces int, s string) string {
+	return "\n" + indent(spaces, s)
+}
+
+// dict create a map of the key value pairs.
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict requires key value pairs, got %d arguments", len(pairs))
+	}
+	d := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		d[toString(pairs[i])] = pairs[i+1]
+	}
+	return d, nil
+}
+
+// get get the value of the key of a map, an empty string if the map has no such key.
+func get(m any, key string) any {
+	if v, ok := lookup(m, key); ok {
+		return v.Interface()
+	}
+	return ""
+}
+
+// hasKey returns true if the map has the key.
+func hasKey(m any, key string) bool {
+	_, ok := lookup(m, key)
+	return ok
+}
+
+// lookup the key in a map with string keys, e.g. the labels of a target.
+func lookup(m any, key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}, false
+	}
+	value := v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+	return value, value.IsValid()
+}
@@ -3,27 +3,38 @@ package job
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"maps"
 	"net"
 	"strconv"
+	"strings"
 	"text/template"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/bakito/batch-job-controller/pkg/config"
 	"github.com/bakito/batch-job-controller/pkg/controller"
 	"github.com/bakito/batch-job-controller/pkg/http"
+	"github.com/bakito/batch-job-controller/pkg/target"
 )
 
 const (
-	envNodeName    = "NODE_NAME"
-	envExecutionID = "EXECUTION_ID"
-	envNamespace   = "NAMESPACE"
+	envNodeName        = "NODE_NAME"
+	envExecutionID     = "EXECUTION_ID"
+	envNamespace       = "NAMESPACE"
+	envTarget          = "TARGET"
+	envTargetKind      = "TARGET_KIND"
+	envTargetName      = "TARGET_NAME"
+	envTargetNamespace = "TARGET_NAMESPACE"
 	// EnvCallbackServiceName env var name of the callback service name.
 	EnvCallbackServiceName = "CALLBACK_SERVICE_NAME"
 	// EnvCallbackServicePort env var name of the callback service port.
@@ -41,6 +52,10 @@ var (
 		envNodeName:            true,
 		envExecutionID:         true,
 		envNamespace:           true,
+		envTarget:              true,
+		envTargetKind:          true,
+		envTargetName:          true,
+		envTargetNamespace:     true,
 		EnvCallbackServiceName: true,
 		EnvCallbackServicePort: true,
 	}
@@ -57,21 +72,41 @@ func MatchingLabels(name string) client.MatchingLabels {
 	return client.MatchingLabels{controller.LabelOwner: name}
 }
 
-// New create a new job.
+// New create a new job of a schedule for a target, the attempt is 0 for the first attempt of the job.
 func New(
 	cfg *config.Config,
-	nodeName, id, callbackAddress string,
+	schedule config.Schedule,
+	t target.Target,
+	id string,
+	attempt int,
+	callbackAddress string,
 	owner runtime.Object,
 	extender ...CustomPodEnv,
 ) (*corev1.Pod, error) {
-	podName := cfg.PodName(nodeName, id)
+	podName := cfg.PodName(t.ID(), id)
 
-	data := map[string]string{
+	var nodeName string
+	var node map[string]any
+	if t.IsNode() {
+		nodeName = t.Name
+		node = map[string]any{
+			"Name":        t.Name,
+			"Labels":      t.Labels,
+			"Annotations": t.Annotations,
+			"Allocatable": allocatable(t.Allocatable),
+		}
+	}
+	data := map[string]any{
 		"Namespace":   cfg.Namespace,
 		"ExecutionID": id,
 		"NodeName":    nodeName,
+		"Node":        node,
+		"Schedule":    schedule.Name,
+		"Attempt":     attempt,
+		"Target":      t,
+		"Custom":      cfg.Custom,
 	}
-	tmpl, err := template.New("job-pod").Parse(cfg.JobPodTemplate)
+	tmpl, err := template.New("job-pod").Funcs(funcMap()).Parse(schedule.JobPodTemplateFor(t))
 	if err != nil {
 		return nil, err
 	}
@@ -100,9 +135,16 @@ func New(
 	// assure correct labels
 	pod.Labels[controller.LabelExecutionID] = id
 	pod.Labels[controller.LabelOwner] = cfg.Name
+	if schedule.Name != "" {
+		pod.Labels[controller.LabelSchedule] = schedule.Name
+	}
+
+	pod.Annotations[controller.AnnotationTarget] = t.ID()
 
-	// assure correct node name
-	pod.Spec.NodeName = nodeName
+	// assure correct node name, jobs of other targets are scheduled by kubernetes
+	if t.IsNode() {
+		pod.Spec.NodeName = nodeName
+	}
 
 	// assure correct service account
 	pod.Spec.ServiceAccountName = cfg.JobServiceAccount
@@ -115,11 +157,11 @@ func New(
 
 	// assure correct env
 	for i := range pod.Spec.Containers {
-		newEnv := mergeEnv(cfg, nodeName, id, callbackAddress, pod.Spec.Containers[i], extender)
+		newEnv := mergeEnv(cfg, t, id, callbackAddress, pod.Spec.Containers[i], extender)
 		pod.Spec.Containers[i].Env = newEnv
 	}
 	for i := range pod.Spec.InitContainers {
-		newEnv := mergeEnv(cfg, nodeName, id, callbackAddress, pod.Spec.InitContainers[i], extender)
+		newEnv := mergeEnv(cfg, t, id, callbackAddress, pod.Spec.InitContainers[i], extender)
 		pod.Spec.InitContainers[i].Env = newEnv
 	}
 
@@ -132,9 +174,61 @@ func New(
 	return pod, err
 }
 
+// Batch wrap a job pod into a batch/v1 job of the job mode Job. The job has the name, labels, annotations and owner of
+// the pod, the name is shortened if needed, see BatchName.
+func Batch(cfg *config.Config, pod *corev1.Pod) *batchv1.Job {
+	bj := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            BatchName(pod.Name),
+			Namespace:       pod.Namespace,
+			Labels:          maps.Clone(pod.Labels),
+			Annotations:     maps.Clone(pod.Annotations),
+			OwnerReferences: pod.OwnerReferences,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: ptr.To(cfg.BatchJob.BackoffLimit),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      maps.Clone(pod.Labels),
+					Annotations: maps.Clone(pod.Annotations),
+				},
+				Spec: *pod.Spec.DeepCopy(),
+			},
+		},
+	}
+	if d := cfg.BatchJob.ActiveDeadline.Duration; d > 0 {
+		bj.Spec.ActiveDeadlineSeconds = ptr.To(int64(d.Seconds()))
+	}
+	if d := cfg.BatchJob.TTLAfterFinished.Duration; d > 0 {
+		bj.Spec.TTLSecondsAfterFinished = ptr.To(int32(d.Seconds()))
+	}
+	return bj
+}
+
+// BatchName get the name of a batch job for the given name. The name of a job is used as label value of its pods and
+// is limited to 63 characters, a longer name is cut and suffixed with a hash of the full name to keep it unique.
+func BatchName(name string) string {
+	if len(name) <= validation.LabelValueMaxLength {
+		return name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	return strings.TrimRight(name[:validation.LabelValueMaxLength-len(suffix)], "-.") + suffix
+}
+
+// allocatable get the allocatable resources of a node as strings, e.g. {"cpu": "4", "memory": "16Gi"}.
+func allocatable(resources corev1.ResourceList) map[string]string {
+	a := make(map[string]string, len(resources))
+	for name, q := range resources {
+		a[string(name)] = q.String()
+	}
+	return a
+}
+
 func mergeEnv(
 	cfg *config.Config,
-	nodeName string,
+	t target.Target,
 	id string,
 	callbackAddress string,
 	container corev1.Container,
@@ -149,13 +243,21 @@ func mergeEnv(
 	}
 
 	for _, e := range extender {
-		newEnv = append(newEnv, e.ExtendEnv(cfg, nodeName, id, callbackAddress, container)...)
+		newEnv = append(newEnv, e.ExtendEnv(cfg, t.ID(), id, callbackAddress, container)...)
 	}
 
 	newEnv = append(newEnv,
 		corev1.EnvVar{Name: envExecutionID, Value: id},
 		corev1.EnvVar{Name: envNamespace, Value: cfg.Namespace},
-		corev1.EnvVar{Name: envNodeName, Value: nodeName},
+	)
+	if t.IsNode() {
+		newEnv = append(newEnv, corev1.EnvVar{Name: envNodeName, Value: t.Name})
+	}
+	newEnv = append(newEnv,
+		corev1.EnvVar{Name: envTarget, Value: t.ID()},
+		corev1.EnvVar{Name: envTargetKind, Value: t.Kind},
+		corev1.EnvVar{Name: envTargetName, Value: t.Name},
+		corev1.EnvVar{Name: envTargetNamespace, Value: t.Namespace},
 		corev1.EnvVar{Name: EnvCallbackServiceName, Value: callbackAddress},
 		corev1.EnvVar{Name: EnvCallbackServicePort, Value: strconv.Itoa(cfg.CallbackServicePort)},
 		corev1.EnvVar{
@@ -163,7 +265,7 @@ func mergeEnv(
 			Value: fmt.Sprintf(
 				"http://%s/report/%s/%s%s", //nolint:revive // ok for internal communication
 				net.JoinHostPort(callbackAddress, strconv.Itoa(cfg.CallbackServicePort)),
-				nodeName,
+				t.ID(),
 				id,
 				http.CallbackBaseResultSubPath,
 			),
@@ -173,7 +275,7 @@ func mergeEnv(
 			Value: fmt.Sprintf(
 				"http://%s/report/%s/%s%s", //nolint:revive // ok for internal communication
 				net.JoinHostPort(callbackAddress, strconv.Itoa(cfg.CallbackServicePort)),
-				nodeName,
+				t.ID(),
 				id,
 				http.CallbackBaseFileSubPath,
 			),
@@ -183,7 +285,7 @@ func mergeEnv(
 			Value: fmt.Sprintf(
 				"http://%s/report/%s/%s%s", //nolint:revive // ok for internal communication
 				net.JoinHostPort(callbackAddress, strconv.Itoa(cfg.CallbackServicePort)),
-				nodeName,
+				t.ID(),
 				id,
 				http.CallbackBaseEventSubPath,
 			),
@@ -1,15 +1,20 @@
 package job
 
 import (
+	"time"
+
 	"github.com/ghodss/yaml"
 	"github.com/google/uuid"
 	"github.com/onsi/gomega/types"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"github.com/bakito/batch-job-controller/pkg/config"
 	"github.com/bakito/batch-job-controller/pkg/controller"
+	"github.com/bakito/batch-job-controller/pkg/target"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -19,9 +24,11 @@ var _ = Describe("Job", func() {
 	Context("New", func() {
 		var (
 			cfg              *config.Config
+			schedule         config.Schedule
 			name             string
 			namespace        string
 			nodeName         string
+			node             target.Target
 			id               string
 			serviceIP        string
 			sacc             string
@@ -37,15 +44,16 @@ var _ = Describe("Job", func() {
 				Namespace:           namespace,
 				JobServiceAccount:   sacc,
 				JobImagePullSecrets: imagePullSecrets,
-				JobPodTemplate:      "kind: Pod",
 				CallbackServicePort: 12345,
 			}
+			schedule = config.Schedule{JobPodTemplate: "kind: Pod"}
 			nodeName = uuid.New().String()
+			node = target.Target{Kind: target.KindNode, Name: nodeName}
 			id = uuid.New().String()
 			serviceIP = "1.1.1.1"
 		})
 		It("should set default fields", func() {
-			pod, err := New(cfg, nodeName, id, serviceIP, nil)
+			pod, err := New(cfg, schedule, node, id, 0, serviceIP, nil)
 			Ω(err).ShouldNot(HaveOccurred())
 			Ω(pod).ShouldNot(BeNil())
 
@@ -58,6 +66,142 @@ var _ = Describe("Job", func() {
 
 			Ω(pod.Labels[controller.LabelExecutionID]).Should(Equal(id))
 			Ω(pod.Labels[controller.LabelOwner]).Should(Equal(name))
+			Ω(pod.Labels).ShouldNot(HaveKey(controller.LabelSchedule))
+			Ω(pod.Annotations[controller.AnnotationTarget]).Should(Equal(nodeName))
+		})
+		It("should not pin the pod of a target that is not a node", func() {
+			cfg.Targets = config.TargetSource{APIVersion: "v1", Kind: "PersistentVolumeClaim"}
+			schedule = config.Schedule{
+				Targets: &cfg.Targets,
+				JobPodTemplate: `kind: Pod
+metadata:
+  annotations:
+    claim: '{{ .Target.Name }}'
+    zone: '{{ index .Target.Labels "zone" }}'`,
+			}
+			pvc := target.Target{
+				Kind:      "PersistentVolumeClaim",
+				Name:      "data",
+				Namespace: "app",
+				Labels:    map[string]string{"zone": "a"},
+			}
+			pod, err := New(cfg, schedule, pvc, id, 0, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(pod.Name).Should(Equal(name + "-job-app-data-" + id))
+			Ω(pod.Spec.NodeName).Should(BeEmpty())
+			Ω(pod.Annotations[controller.AnnotationTarget]).Should(Equal("app.data"))
+			Ω(pod.Annotations["claim"]).Should(Equal("data"))
+			Ω(pod.Annotations["zone"]).Should(Equal("a"))
+		})
+		It("should use the template and label of a named schedule", func() {
+			schedule = config.Schedule{
+				Name:           "nightly",
+				JobPodTemplate: "kind: Pod\nmetadata:\n  annotations:\n    schedule: '{{ .Schedule }}'",
+			}
+			pod, err := New(cfg, schedule, node, id, 0, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(pod.Labels[controller.LabelSchedule]).Should(Equal("nightly"))
+			Ω(pod.Annotations["schedule"]).Should(Equal("nightly"))
+		})
+
+		It("should use the pod template of the node group", func() {
+			node.Labels = map[string]string{"kubernetes.io/arch": "arm64"}
+			schedule = config.Schedule{
+				JobPodTemplate: "kind: Pod\nmetadata:\n  annotations:\n    template: default",
+				PodTemplates: []config.PodTemplateSelector{{
+					NodeSelector:   map[string]string{"kubernetes.io/arch": "arm64"},
+					JobPodTemplate: "kind: Pod\nmetadata:\n  annotations:\n    template: arm64",
+				}},
+			}
+			pod, err := New(cfg, schedule, node, id, 0, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pod.Annotations["template"]).Should(Equal("arm64"))
+
+			node.Labels = map[string]string{"kubernetes.io/arch": "amd64"}
+			pod, err = New(cfg, schedule, node, id, 0, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(pod.Annotations["template"]).Should(Equal("default"))
+		})
+
+		It("should provide the fields of a target list entry", func() {
+			cfg.Targets = config.TargetSource{Static: []any{}}
+			schedule = config.Schedule{
+				Targets:        &cfg.Targets,
+				JobPodTemplate: "kind: Pod\nmetadata:\n  annotations:\n    host: '{{ .Target.Fields.host }}'",
+			}
+			entries, err := target.FromEntries([]any{map[string]any{"name": "shard-1", "host": "db-1.example.com"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			pod, err := New(cfg, schedule, entries[0], id, 0, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(pod.Name).Should(Equal(name + "-job-shard-1-" + id))
+			Ω(pod.Annotations["host"]).Should(Equal("db-1.example.com"))
+		})
+
+		It("should provide the node, attempt and custom config", func() {
+			cfg.Custom = map[string]any{"image": map[string]any{"arm64": "app:arm"}}
+			node.Labels = map[string]string{"kubernetes.io/arch": "arm64"}
+			node.Annotations = map[string]string{"pool": "batch"}
+			node.Allocatable = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}
+			schedule = config.Schedule{
+				JobPodTemplate: `kind: Pod
+metadata:
+  annotations:
+    image: '{{ index .Custom.image (index .Node.Labels "kubernetes.io/arch") }}'
+    pool: '{{ .Node.Annotations.pool }}'
+    cpu: '{{ .Node.Allocatable.cpu }}'
+    attempt: '{{ .Attempt }}'`,
+			}
+			pod, err := New(cfg, schedule, node, id, 2, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(pod.Annotations["image"]).Should(Equal("app:arm"))
+			Ω(pod.Annotations["pool"]).Should(Equal("batch"))
+			Ω(pod.Annotations["cpu"]).Should(Equal("4"))
+			Ω(pod.Annotations["attempt"]).Should(Equal("2"))
+		})
+
+		It("should provide the template functions", func() {
+			node.Labels = map[string]string{"pool": "GPU"}
+			schedule = config.Schedule{
+				JobPodTemplate: `{{- $zone := get .Node.Labels "zone" | default "none" -}}
+kind: Pod
+metadata:
+  labels: {{ dict "pool" (get .Node.Labels "pool" | lower) "zone" $zone | toYaml | nindent 4 }}
+  annotations:
+    quoted: {{ .Schedule | default "default" | quote }}
+    hasZone: '{{ hasKey .Node.Labels "zone" }}'
+    upper: '{{ "a" | upper }}'`,
+			}
+			pod, err := New(cfg, schedule, node, id, 0, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(pod.Labels).Should(HaveKeyWithValue("pool", "gpu"))
+			Ω(pod.Labels).Should(HaveKeyWithValue("zone", "none"))
+			Ω(pod.Annotations["quoted"]).Should(Equal("default"))
+			Ω(pod.Annotations["hasZone"]).Should(Equal("false"))
+			Ω(pod.Annotations["upper"]).Should(Equal("A"))
+		})
+
+		It("should not provide the node for other targets", func() {
+			schedule = config.Schedule{
+				JobPodTemplate: `kind: Pod
+metadata:
+  annotations:
+    node: '{{ with .Node }}{{ .Name }}{{ else }}none{{ end }}'`,
+			}
+			pod, err := New(cfg, schedule, target.Target{Kind: target.KindEntry, Name: "shard-1"}, id, 0, serviceIP, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(pod.Annotations["node"]).Should(Equal("none"))
+		})
+
+		It("should return an error for an unknown function", func() {
+			schedule = config.Schedule{JobPodTemplate: "kind: {{ env \"HOME\" }}"}
+			_, err := New(cfg, schedule, node, id, 0, serviceIP, nil)
+			Ω(err).Should(MatchError(ContainSubstring(`function "env" not defined`)))
 		})
 
 		Context("Env vars", func() {
@@ -85,14 +229,16 @@ var _ = Describe("Job", func() {
 					},
 				}
 				b, _ := yaml.Marshal(pod)
-				cfg.JobPodTemplate = string(b)
+				schedule.JobPodTemplate = string(b)
 			})
 			It("should set default env vars", func() {
-				pod, _ := New(cfg, nodeName, id, serviceIP, nil)
+				pod, _ := New(cfg, schedule, node, id, 0, serviceIP, nil)
 
 				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(envExecutionID, id))
 				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(envNamespace, namespace))
 				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(envNodeName, nodeName))
+				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(envTarget, nodeName))
+				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(envTargetKind, target.KindNode))
 				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(EnvCallbackServiceName, serviceIP))
 				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(EnvCallbackServicePort, "12345"))
 				Ω(
@@ -126,7 +272,7 @@ var _ = Describe("Job", func() {
 			It("should have a correct owner reference", func() {
 				ownerID := uuid.New().String()
 				ownerName := uuid.New().String()
-				pod, _ := New(cfg, nodeName, id, serviceIP, &corev1.Pod{
+				pod, _ := New(cfg, schedule, node, id, 0, serviceIP, &corev1.Pod{
 					ObjectMeta: metav1.ObjectMeta{
 						UID:  ktypes.UID(ownerID),
 						Name: ownerName,
@@ -139,13 +285,123 @@ var _ = Describe("Job", func() {
 			})
 
 			It("should have a correct custom env variables reference", func() {
-				pod, _ := New(cfg, nodeName, id, serviceIP, nil, &customEnv{})
+				pod, _ := New(cfg, schedule, node, id, 0, serviceIP, nil, &customEnv{})
 
 				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar(envNamespace, namespace))
 				Ω(pod.Spec.Containers[0].Env).Should(HaveEnvVar("CUSTOM", "VALUE"))
 			})
 		})
 	})
+	Context("funcMap", func() {
+		It("should return the default of empty values", func() {
+			Ω(defaultValue("a", "")).Should(Equal("a"))
+			Ω(defaultValue("a", nil)).Should(Equal("a"))
+			Ω(defaultValue(1, 0)).Should(Equal(1))
+			Ω(defaultValue("a", "b")).Should(Equal("b"))
+			Ω(defaultValue("a", map[string]string{})).Should(Equal("a"))
+			Ω(coalesce("", nil, "c", "d")).Should(Equal("c"))
+			Ω(coalesce("", nil)).Should(BeNil())
+		})
+		It("should quote the values", func() {
+			Ω(quote("a", 1)).Should(Equal(`"a" "1"`))
+			Ω(quote(nil)).Should(Equal(`""`))
+			Ω(squote("it's")).Should(Equal(`'it''s'`))
+		})
+		It("should indent the lines", func() {
+			Ω(indent(2, "a\nb")).Should(Equal("  a\n  b"))
+			Ω(nindent(2, "a")).Should(Equal("\n  a"))
+		})
+		It("should render yaml and json", func() {
+			y, err := toYaml(map[string]any{"a": 1, "b": []string{"c"}})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(y).Should(Equal("a: 1\nb:\n- c"))
+			j, err := toJSON(map[string]string{"a": "b"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(j).Should(Equal(`{"a":"b"}`))
+		})
+		It("should create and look up dicts", func() {
+			d, err := dict("a", 1, "b", "c")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(d).Should(Equal(map[string]any{"a": 1, "b": "c"}))
+			_, err = dict("a")
+			Ω(err).Should(HaveOccurred())
+
+			labels := map[string]string{"a": "b"}
+			Ω(get(labels, "a")).Should(Equal("b"))
+			Ω(get(labels, "x")).Should(Equal(""))
+			Ω(get(nil, "a")).Should(Equal(""))
+			Ω(get(corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}, "cpu")).
+				Should(Equal(resource.MustParse("1")))
+			Ω(hasKey(labels, "a")).Should(BeTrue())
+			Ω(hasKey(labels, "x")).Should(BeFalse())
+			Ω(hasKey("a", "a")).Should(BeFalse())
+		})
+	})
+	Context("Batch", func() {
+		var (
+			cfg *config.Config
+			pod *corev1.Pod
+		)
+		BeforeEach(func() {
+			cfg = &config.Config{JobMode: config.JobModeJob}
+			pod = &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "job-node-a-1",
+					Namespace:       "ns",
+					Labels:          map[string]string{controller.LabelExecutionID: "1"},
+					Annotations:     map[string]string{controller.AnnotationTarget: "node-a"},
+					OwnerReferences: []metav1.OwnerReference{{Name: "owner"}},
+				},
+				Spec: corev1.PodSpec{NodeName: "node-a", RestartPolicy: corev1.RestartPolicyNever},
+			}
+		})
+		It("should wrap the job pod", func() {
+			bj := Batch(cfg, pod)
+
+			Ω(bj.Name).Should(Equal(pod.Name))
+			Ω(bj.Namespace).Should(Equal("ns"))
+			Ω(bj.Labels).Should(Equal(pod.Labels))
+			Ω(bj.Annotations).Should(Equal(pod.Annotations))
+			Ω(bj.OwnerReferences).Should(Equal(pod.OwnerReferences))
+			Ω(*bj.Spec.BackoffLimit).Should(Equal(int32(0)))
+			Ω(bj.Spec.ActiveDeadlineSeconds).Should(BeNil())
+			Ω(bj.Spec.TTLSecondsAfterFinished).Should(BeNil())
+			Ω(bj.Spec.Template.Labels).Should(Equal(pod.Labels))
+			Ω(bj.Spec.Template.Annotations).Should(Equal(pod.Annotations))
+			Ω(bj.Spec.Template.Spec).Should(Equal(pod.Spec))
+		})
+		It("should apply the batch job settings", func() {
+			cfg.BatchJob = config.BatchJob{
+				BackoffLimit:     2,
+				ActiveDeadline:   metav1.Duration{Duration: time.Hour},
+				TTLAfterFinished: metav1.Duration{Duration: time.Minute},
+			}
+			bj := Batch(cfg, pod)
+
+			Ω(*bj.Spec.BackoffLimit).Should(Equal(int32(2)))
+			Ω(*bj.Spec.ActiveDeadlineSeconds).Should(Equal(int64(3600)))
+			Ω(*bj.Spec.TTLSecondsAfterFinished).Should(Equal(int32(60)))
+		})
+		It("should shorten the name of a job of a long target name", func() {
+			cfg.Name = "batch-job-controller"
+			target := "storage-node-with-a-very-long-name-in-the-cluster"
+			pod.Name = cfg.PodName(target, "202001021504")
+			Ω(len(pod.Name)).Should(BeNumerically(">", 63))
+
+			bj := Batch(cfg, pod)
+			Ω(len(bj.Name)).Should(BeNumerically("<=", 63))
+			Ω(bj.Name).Should(MatchRegexp(`^batch-job-controller-job-storage-node-with-a-very-long-[0-9a-f]{8}$`))
+			Ω(validation.IsDNS1123Label(bj.Name)).Should(BeEmpty())
+			Ω(Batch(cfg, pod).Name).Should(Equal(bj.Name))
+
+			other := pod.DeepCopy()
+			other.Name = cfg.PodName(target, "202001021505")
+			Ω(Batch(cfg, other).Name).ShouldNot(Equal(bj.Name))
+		})
+		It("should keep a short name", func() {
+			Ω(BatchName("job-node-a-1")).Should(Equal("job-node-a-1"))
+		})
+	})
 })
 
 type customEnv struct{}
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -18,13 +21,17 @@ import (
 
 	"github.com/bakito/batch-job-controller/pkg/config"
 	"github.com/bakito/batch-job-controller/pkg/metrics"
+	"github.com/bakito/batch-job-controller/pkg/webhook"
 )
 
-var log = ctrl.Log.WithName("lifecycle")
+var (
+	log             = ctrl.Log.WithName("lifecycle")
+	sequencePattern = regexp.MustCompile(`^(.+)-(\d{3})$`)
+)
 
 // NewController get a new controller.
 func NewController(cfg *config.Config, prom *metrics.Collector) Controller {
-	return &controller{
+	c := &controller{
 		executions:    make(map[string]*execution),
 		nodes:         make(map[string]bool),
 		prom:          prom,
@@ -33,22 +40,60 @@ func NewController(cfg *config.Config, prom *metrics.Collector) Controller {
 		reportDir:     cfg.ReportDirectory,
 		podPoolSize:   cfg.PodPoolSize,
 		config:        *cfg,
+		webhooks:      webhook.New(cfg),
+	}
+	if c.config.ExecutionIDFormat == "" {
+		c.config.ExecutionIDFormat = config.DefaultExecutionIDFormat
 	}
+	return c
 }
 
 // Controller interface.
 type Controller interface {
-	NewExecution(nbrOrJobs int) string
+	// NewExecution setup a new execution of the schedule with the given name
+	NewExecution(schedule string, nbrOrJobs int) string
+	// NewPartialExecution setup a new execution of some targets of the schedule, e.g. of a node that became ready.
+	// It does not become the latest execution and does not count for the report history.
+	NewPartialExecution(schedule string, nbrOrJobs int) string
 	AllAdded(executionID string) error
+	// AddPod add the job of a node to the execution, it is started right away if all jobs were added already.
+	// Returns ErrExecutionFinished if the execution is finished.
 	AddPod(job Job) error
 	PodTerminated(executionID, node string, phase corev1.PodPhase) error
+	// PodFailed the pod of a job can not run to completion. The pod is deleted and the reason is recorded as status.
+	// Returns true if the failure was recorded, false if the pod was already terminated.
+	PodFailed(executionID, node, reason string) (bool, error)
 	ReportReceived(executionID, node string, processingError error, results metrics.Results)
+	// FileReceived a file was uploaded by the pod of a node
+	FileReceived(executionID, node, fileName string)
 	Config() config.Config
 	// Has return true if the executionId is known
 	Has(node string, executionID string) bool
+	// Restore rebuild an execution from its persisted state and the job pods still existing
+	Restore(executionID string, pods []RestoredPod) error
+	// Cancel stop dispatching the pending jobs of an execution and delete its running pods
+	Cancel(executionID, reason string) error
+	// Progress the overall progress of the current execution
+	Progress() string
+	// Executions the status of all known executions, the latest first
+	Executions() []*ExecutionStatus
+	// Execution the status of an execution including the status of its nodes
+	Execution(executionID string) (*ExecutionStatus, error)
+	// Attempt the current attempt of the job of a node, 0 if unknown
+	Attempt(executionID, node string) int
+	// FailedNodes the nodes of a finished execution whose pod did not succeed or did not send a report
+	FailedNodes(executionID string) ([]string, error)
+	// LinkRerun link an execution as rerun of the failed nodes of the original execution
+	LinkRerun(originalID, executionID string) error
+	// AwaitNodes wait until the pods of the nodes are terminated and return the number of nodes that failed.
+	// Returns ErrExecutionCancelled if the execution was cancelled meanwhile.
+	AwaitNodes(executionID string, nodes []string) (int, error)
+	// Finished get a channel that is closed when all pods of the execution are terminated
+	Finished(executionID string) (<-chan struct{}, error)
 }
 
 type controller struct {
+	mux           sync.RWMutex
 	prom          *metrics.Collector
 	executions    map[string]*execution
 	nodes         map[string]bool
@@ -57,15 +102,43 @@ type controller struct {
 	reportHistory int
 	podPoolSize   int
 	config        config.Config
-	progress      uint64
-	progressStep  float64
+	webhooks      *webhook.Notifier
+	// current the latest execution
+	current *execution
 }
 
 type execution struct {
 	sync.Map
-	id         string
+	id string
+	// schedule the name of the schedule of the execution, empty for the default schedule
+	schedule   string
+	started    time.Time
 	jobChan    chan Job
 	controller *controller
+	stateMux   sync.Mutex
+	cancelled  atomic.Bool
+	// restored is true if the execution was rebuilt after a restart and has no workers
+	restored bool
+	// partial is true for an execution of some targets of the schedule, see NewPartialExecution
+	partial bool
+	// addMux guards adding jobs against closing the job pool and finishing the execution
+	addMux sync.Mutex
+	// allAdded is true if all jobs of the execution were added
+	allAdded atomic.Bool
+	// finished the time all pods of the execution were terminated
+	finished atomic.Pointer[time.Time]
+	// done is closed when the execution is finished
+	done chan struct{}
+	// rerunOf the id of the execution whose failed nodes are rerun by this execution, guarded by stateMux
+	rerunOf string
+	// rerunBy the ids of the executions rerunning the failed nodes of this execution, guarded by stateMux
+	rerunBy []string
+	// progress the number of completed steps, each pod has 3 steps
+	progress atomic.Uint64
+	// jobs the number of jobs of the execution, it grows if jobs are added to the running execution
+	jobs atomic.Int64
+	// added the number of jobs added to the execution
+	added atomic.Int64
 }
 
 // verify interface is implemented.
@@ -76,31 +149,66 @@ func (c *controller) Config() config.Config {
 	return c.config
 }
 
+// getProgress get the progress of the current execution.
 func (c *controller) getProgress() string {
-	return fmt.Sprintf("%.f%%", c.progressStep*float64(c.progress))
+	c.mux.RLock()
+	e := c.current
+	c.mux.RUnlock()
+	if e == nil {
+		return "0%"
+	}
+	return e.getProgress()
+}
+
+func (e *execution) getProgress() string {
+	jobs := e.jobs.Load()
+	if jobs == 0 {
+		return "0%"
+	}
+	step := 100 / (float64(jobs) * 3)
+	return fmt.Sprintf("%.f%%", step*float64(e.progress.Load()))
 }
 
-func (c *controller) addProgress(p uint64) {
-	atomic.AddUint64(&c.progress, p)
+func (e *execution) addProgress(p uint64) {
+	e.progress.Add(p)
 }
 
-// NewExecution setup a new execution.
-func (c *controller) NewExecution(jobs int) string {
-	//                       yyyyMMddHHmm
-	id := time.Now().Format("200601021504")
+// NewExecution setup a new execution of the schedule with the given name.
+func (c *controller) NewExecution(schedule string, jobs int) string {
+	return c.newExecution(schedule, jobs, false)
+}
+
+// NewPartialExecution setup a new execution of some targets of the schedule.
+func (c *controller) NewPartialExecution(schedule string, jobs int) string {
+	return c.newExecution(schedule, jobs, true)
+}
+
+func (c *controller) newExecution(schedule string, jobs int, partial bool) string {
+	poolSize := c.podPoolSize
+	if s, ok := c.config.ScheduleFor(schedule); ok && s.PodPoolSize > 0 {
+		poolSize = s.PodPoolSize
+	}
+	fj := float64(jobs)
+
+	c.mux.Lock()
+	id := c.newExecutionID(schedule)
 	e := &execution{
 		id:         id,
-		jobChan:    make(chan Job, c.podPoolSize),
+		schedule:   schedule,
+		started:    time.Now(),
+		jobChan:    make(chan Job, poolSize),
 		controller: c,
+		partial:    partial,
+		done:       make(chan struct{}),
 	}
+	e.jobs.Store(int64(jobs))
 	c.executions[id] = e
+	if !partial {
+		c.current = e
+	}
+	c.mux.Unlock()
 
-	fj := float64(jobs)
-	c.progressStep = 100 / (fj * 3)
-	atomic.StoreUint64(&c.progress, 0)
-	c.prom.Pods(fj)
-
-	for w := 1; w <= c.podPoolSize; w++ {
+	for w := 1; w <= poolSize; w++ {
 		go e.worker(w)
 	}
 
@@ -112,26 +220,77 @@ func (c *controller) NewExecution(jobs int) string {
 			c.log.WithValues("dir", reportDir).Error(err, "error creating directory")
 		}
 	}
+	e.saveState()
 
-	if runtime.GOOS != "windows" {
-		symlink := filepath.Join(c.reportDir, "latest")
-		if _, err := os.Lstat(symlink); err == nil {
-			err := os.Remove(symlink)
-			if err != nil {
-				c.log.WithValues("dir", symlink).Error(err, "error deleting latest link")
-			}
-		}
-		err := os.Symlink(reportDir, symlink)
+	if !partial {
+		c.linkLatest(reportDir)
+		c.prom.Pods(schedule, fj)
+		c.prom.ExecutionStarted(schedule, executionIDValue(strings.TrimPrefix(id, config.ExecutionIDPrefix(schedule))))
+	}
+	c.webhooks.Notify(webhook.Payload{
+		Event:       config.WebhookEventStarted,
+		ExecutionID: id,
+		Started:     e.started,
+		Nodes:       jobs,
+	})
+	return id
+}
+
+// linkLatest point the latest link to the report directory.
+func (c *controller) linkLatest(reportDir string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	symlink := filepath.Join(c.reportDir, "latest")
+	if _, err := os.Lstat(symlink); err == nil {
+		err := os.Remove(symlink)
 		if err != nil {
-			c.log.WithValues("dir", symlink).Error(err, "error creating latest link")
+			c.log.WithValues("dir", symlink).Error(err, "error deleting latest link")
 		}
 	}
-	f, _ := strconv.ParseFloat(id, 64)
+	err := os.Symlink(reportDir, symlink)
+	if err != nil {
+		c.log.WithValues("dir", symlink).Error(err, "error creating latest link")
+	}
+}
 
-	c.prom.ExecutionStarted(f)
+// newExecutionID create a new unique execution id from the configured format, prefixed with the name of the schedule.
+// If the id is already in use, a zero padded sequence is appended to keep the report directories sortable.
+func (c *controller) newExecutionID(schedule string) string {
+	base := config.ExecutionIDPrefix(schedule) + time.Now().Format(c.config.ExecutionIDFormat)
+	id := base
+	for seq := 1; c.isUsed(id); seq++ {
+		id = fmt.Sprintf("%s-%03d", base, seq)
+	}
 	return id
 }
 
+func (c *controller) isUsed(id string) bool {
+	if _, ok := c.executions[id]; ok {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(c.reportDir, id))
+	return err == nil
+}
+
+// executionIDValue get the numeric value of an execution id for the current execution id metric.
+// The digits of the timestamp are used as value, a sequence is added as decimal fraction.
+func executionIDValue(id string) float64 {
+	base, seq := id, 0.
+	if m := sequencePattern.FindStringSubmatch(id); m != nil {
+		base = m[1]
+		seq, _ = strconv.ParseFloat(m[2], 64)
+	}
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, base)
+	v, _ := strconv.ParseFloat(digits, 64)
+	return v + seq/1000
+}
+
 // AllAdded start the processing.
 func (c *controller) AllAdded(executionID string) error {
 	e, err := c.forID(executionID)
@@ -139,12 +298,34 @@ func (c *controller) AllAdded(executionID string) error {
 		return err
 	}
 
+	if !e.partial {
+		if err := c.prune(e.schedule); err != nil {
+			return err
+		}
+	}
+
+	e.addMux.Lock()
+	close(e.jobChan)
+	e.allAdded.Store(true)
+	e.addMux.Unlock()
+	e.checkFinished()
+	return nil
+}
+
+// prune delete the reports of the schedule exceeding the report history. Partial executions do not count for the
+// history, they are deleted with the executions started before them.
+func (c *controller) prune(schedule string) error {
 	files, err := os.ReadDir(c.reportDir)
 	if err != nil {
 		c.log.WithValues("dir ", c.reportDir).Error(err, "could not list report dir files")
 		return err
 	}
 
+	// only the reports of the same schedule are pruned, hidden files are no reports
+	files = slices.DeleteFunc(files, func(f os.DirEntry) bool {
+		return strings.HasPrefix(f.Name(), ".") || c.config.ScheduleOf(f.Name()) != schedule
+	})
+
 	slices.SortFunc(files, func(i, j os.DirEntry) int {
 		ii, _ := i.Info()
 		ji, _ := j.Info()
@@ -157,47 +338,216 @@ func (c *controller) AllAdded(executionID string) error {
 		return 0
 	})
 
-	if len(files) > c.reportHistory {
-		pruneCnt := len(files) - c.reportHistory
-		for i := range pruneCnt {
-			name := files[i].Name()
-			// delete the execution
-			delete(c.executions, name)
-			c.prom.Prune(name)
-
-			dir := filepath.Join(c.reportDir, name)
-			c.log.WithValues("dir", dir).Info("deleting report directory")
-			err = os.RemoveAll(dir)
-			if err != nil {
-				c.log.WithValues("dir", dir).Error(err, "could delete report directory")
-			}
+	// the oldest report to keep
+	keep := 0
+	for i, kept := len(files)-1, 0; i >= 0; i-- {
+		if c.isPartial(files[i].Name()) {
+			continue
+		}
+		if kept++; kept == c.reportHistory {
+			keep = i
+			break
+		}
+	}
+	for i := range keep {
+		name := files[i].Name()
+		// delete the execution
+		c.mux.Lock()
+		delete(c.executions, name)
+		c.mux.Unlock()
+		c.prom.Prune(name)
+
+		dir := filepath.Join(c.reportDir, name)
+		c.log.WithValues("dir", dir).Info("deleting report directory")
+		err = os.RemoveAll(dir)
+		if err != nil {
+			c.log.WithValues("dir", dir).Error(err, "could delete report directory")
 		}
 	}
-	close(e.jobChan)
 	return nil
 }
 
+// isPartial returns true if the report directory is of a partial execution.
+func (c *controller) isPartial(executionID string) bool {
+	st, err := c.loadState(executionID)
+	return err == nil && st.Partial
+}
+
 func (e *execution) worker(id int) {
 	l := log.WithName("worker").WithValues("workerID", id)
 	l.V(4).Info("initialized")
 	for job := range e.jobChan {
-		l.V(4).Info("process job", "jobID", job.ID(), "nodeName", job.Node())
-		job.CreatePod()
-
-		p, err := e.pod(job.Node())
-		if err != nil {
+		if !e.process(job, l) {
 			return
 		}
-		p.started = time.Now()
-		p.status = "Started"
-		e.controller.addProgress(1)
+	}
+}
 
-		for p.terminated == nil {
-			time.Sleep(time.Second)
+// process start the pod of the job and wait until it is terminated. Returns false if the pod of the job is not known.
+func (e *execution) process(job Job, l logr.Logger) bool {
+	p, err := e.pod(job.Node())
+	if err != nil {
+		return false
+	}
+
+	p.mux.Lock()
+	if e.cancelled.Load() && p.terminate(statusCancelled) {
+		e.controller.prom.Cancelled(job.Node(), job.ID())
+		e.addProgress(3)
+	}
+	if p.terminated != nil {
+		// the job was cancelled before it could be started
+		p.mux.Unlock()
+		l.V(4).Info("skip job", "jobID", job.ID(), "nodeName", job.Node())
+		e.checkFinished()
+		return true
+	}
+	l.V(4).Info("process job", "jobID", job.ID(), "nodeName", job.Node())
+	job.CreatePod()
+	p.started = time.Now()
+	p.status = statusStarted
+	p.mux.Unlock()
+
+	e.saveState()
+	e.addProgress(1)
+
+	for e.wait(p) {
+		if !e.retry(p, l) {
+			break
 		}
-		e.controller.addProgress(1)
-		l.WithValues("jobID", job.ID(), "nodeName", job.Node(), "progress", e.controller.getProgress()).Info("job terminated")
 	}
+	e.addProgress(1)
+	l.WithValues("jobID", job.ID(), "nodeName", job.Node(), "progress", e.getProgress()).Info("job terminated")
+	return true
+}
+
+// wait until the pod is terminated or the job timeout is exceeded. Returns true if the failed attempt is to be retried.
+func (e *execution) wait(p *pod) bool {
+	p.mux.Lock()
+	retry := p.retry
+	p.mux.Unlock()
+
+	var timeoutC <-chan time.Time
+	timeout := e.controller.config.JobTimeout.Duration
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	select {
+	case <-p.done:
+		return false
+	case <-retry:
+		return true
+	case <-timeoutC:
+		return e.timedOut(p, timeout)
+	}
+}
+
+// watchTimeout time out the pod of a restored execution if it exceeds the job timeout, the time the pod ran before the
+// restart of the controller is taken into account. A restored execution has no worker waiting for the termination.
+func (e *execution) watchTimeout(p *pod, timeout time.Duration) {
+	p.mux.Lock()
+	started := p.started
+	p.mux.Unlock()
+
+	timer := time.NewTimer(max(timeout-time.Since(started), 0))
+	defer timer.Stop()
+	select {
+	case <-p.done:
+	case <-timer.C:
+		e.timedOut(p, timeout)
+	}
+}
+
+// timedOut delete the pod of a job that exceeded the job timeout. Returns true if the failed attempt is to be retried.
+func (e *execution) timedOut(p *pod, timeout time.Duration) bool {
+	p.mux.Lock()
+	if p.terminated != nil || p.retrying() {
+		// terminated in the meantime
+		p.mux.Unlock()
+		return false
+	}
+	if p.job != nil {
+		p.job.DeletePod()
+	}
+	if e.scheduleRetry(p) {
+		p.mux.Unlock()
+		e.saveState()
+		e.controller.log.WithValues("id", e.id, "node", p.node, "timeout", timeout).Info("job timed out, retrying")
+		return true
+	}
+	p.terminate(statusTimedOut)
+	duration := p.terminated.Sub(p.started)
+	p.mux.Unlock()
+
+	e.controller.prom.Duration(p.node, e.id, float64(duration.Milliseconds()))
+	e.controller.prom.ProcessingFinished(p.node, e.id, true)
+	e.saveState()
+	e.checkFinished()
+	// the pod is terminated
+	e.addProgress(1)
+	if e.restored {
+		// there is no worker that tracks the termination
+		e.addProgress(1)
+	}
+	e.controller.log.WithValues(
+		"id", e.id,
+		"node", p.node,
+		"timeout", timeout,
+	).Info("job timed out")
+	return false
+}
+
+// PodFailed the pod of a job can not run to completion.
+func (c *controller) PodFailed(executionID, node, reason string) (bool, error) {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return false, err
+	}
+	p, err := e.pod(node)
+	if err != nil {
+		return false, err
+	}
+
+	p.mux.Lock()
+	if p.terminated != nil || p.retrying() {
+		p.mux.Unlock()
+		return false, nil
+	}
+	if p.job != nil {
+		p.job.DeletePod()
+	}
+	if e.scheduleRetry(p) {
+		p.mux.Unlock()
+		e.saveState()
+		c.log.WithValues("id", executionID, "node", node, "reason", reason).Info("pod failed, retrying")
+		return true, nil
+	}
+	p.terminate(reason)
+	duration := p.terminated.Sub(p.started)
+	p.mux.Unlock()
+
+	defer e.checkFinished()
+	defer e.saveState()
+	// the pod is terminated
+	e.addProgress(1)
+	if e.restored {
+		// there is no worker that tracks the termination
+		e.addProgress(1)
+	}
+	c.prom.Duration(node, executionID, float64(duration.Milliseconds()))
+	c.prom.Failed(node, reason, executionID)
+	c.prom.ProcessingFinished(node, executionID, true)
+
+	c.log.WithValues(
+		"id", executionID,
+		"node", node,
+		"reason", reason,
+		"progress", e.getProgress(),
+	).Info("pod failed")
+	return true, nil
 }
 
 // AddPod add a new pod.
@@ -206,40 +556,114 @@ func (c *controller) AddPod(job Job) error {
 	if err != nil {
 		return err
 	}
+	e.addMux.Lock()
+	defer e.addMux.Unlock()
+	if e.finished.Load() != nil {
+		return ErrExecutionFinished
+	}
+	c.mux.Lock()
 	c.nodes[job.Node()] = true
-	e.Store(job.Node(), &pod{
-		node: job.Node(),
-	})
+	c.mux.Unlock()
+	if _, ok := e.Load(job.Node()); !ok && e.added.Add(1) > e.jobs.Load() {
+		// the job of a node was added to the running execution
+		jobs := e.jobs.Add(1)
+		if !e.partial {
+			c.prom.Pods(e.schedule, float64(jobs))
+		}
+	}
+	e.Store(job.Node(), newPod(job.Node(), job))
+	e.saveState()
+	if e.allAdded.Load() {
+		// the job pool is closed already, the job gets a worker of its own
+		go e.process(job, log.WithName("worker").WithValues("nodeName", job.Node()))
+		return nil
+	}
 	e.jobChan <- job
 	return nil
 }
 
+// AwaitNodes wait until the pods of the nodes are terminated and return the number of nodes whose pod did not succeed
+// or did not send a report.
+func (c *controller) AwaitNodes(executionID string, nodes []string) (int, error) {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return 0, err
+	}
+	var failed int
+	for _, node := range nodes {
+		p, err := e.pod(node)
+		if err != nil {
+			return 0, err
+		}
+		<-p.done
+		p.mux.Lock()
+		if p.status != string(corev1.PodSucceeded) || p.reportReceived == nil {
+			failed++
+		}
+		p.mux.Unlock()
+	}
+	if e.cancelled.Load() {
+		return failed, ErrExecutionCancelled
+	}
+	return failed, nil
+}
+
+// Finished get a channel that is closed when all pods of the execution are terminated.
+func (c *controller) Finished(executionID string) (<-chan struct{}, error) {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	return e.done, nil
+}
+
 // PodTerminated pod was terminated.
 func (c *controller) PodTerminated(executionID, node string, phase corev1.PodPhase) error {
-	p, err := c.podForID(executionID, node)
+	e, err := c.forID(executionID)
 	if err != nil {
 		return err
 	}
-	if p.terminated != nil {
+	p, err := e.pod(node)
+	if err != nil {
+		return err
+	}
+
+	p.mux.Lock()
+	if p.terminated != nil || p.retrying() {
+		p.mux.Unlock()
 		return nil
 	}
-	c.addProgress(1)
-	t := time.Now()
-	p.terminated = &t
-	p.status = string(phase)
-	c.prom.Duration(node, executionID, float64(t.Sub(p.started).Milliseconds()))
+	if (phase != corev1.PodSucceeded || p.reportReceived == nil) && e.scheduleRetry(p) {
+		p.mux.Unlock()
+		e.saveState()
+		c.log.WithValues("id", executionID, "node", node, "result", phase).Info("pod was not successful, retrying")
+		return nil
+	}
+	p.terminate(string(phase))
+	duration := p.terminated.Sub(p.started)
+	reportReceived := p.reportReceived != nil
+	p.mux.Unlock()
+
+	defer e.checkFinished()
+	defer e.saveState()
+	e.addProgress(1)
+	if e.restored {
+		// there is no worker that tracks the termination
+		e.addProgress(1)
+	}
+	c.prom.Duration(node, executionID, float64(duration.Milliseconds()))
 
 	l := c.log.WithValues(
 		"result ", phase,
 		"node", node,
-		"reports", p.reportReceived != nil,
-		"progress", c.getProgress(),
+		"reports", reportReceived,
+		"progress", e.getProgress(),
 	)
 
 	// if not successful or not report received report an error
-	if phase != corev1.PodSucceeded || p.reportReceived == nil {
+	if phase != corev1.PodSucceeded || !reportReceived {
 		msg := "pod was not successful"
-		if p.reportReceived == nil {
+		if !reportReceived {
 			msg = "did not receive report"
 		}
 		c.prom.ProcessingFinished(node, executionID, true)
@@ -270,12 +694,99 @@ func (c *controller) ReportReceived(executionID, node string, processingError er
 		return
 	}
 
+	p.mux.Lock()
 	t := time.Now()
 	p.reportReceived = &t
-	p.status = "ReportReceived"
+	if p.terminated == nil {
+		p.status = "ReportReceived"
+	}
+	p.mux.Unlock()
+	e.saveState()
+	e.updateSummary()
+}
+
+// FileReceived a file was uploaded by the pod of a node.
+func (c *controller) FileReceived(executionID, node, fileName string) {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return
+	}
+
+	p, err := e.pod(node)
+	if err != nil {
+		return
+	}
+
+	p.mux.Lock()
+	p.files = append(p.files, fileName)
+	p.mux.Unlock()
+	e.saveState()
+	e.updateSummary()
+}
+
+// Cancel stop dispatching the pending jobs of an execution and delete its running pods.
+func (c *controller) Cancel(executionID, reason string) error {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return err
+	}
+	if e.cancelled.Load() {
+		// already cancelled
+		return nil
+	}
+	if e.finished.Load() != nil {
+		return ErrExecutionFinished
+	}
+	if !e.cancelled.CompareAndSwap(false, true) {
+		// already cancelled
+		return nil
+	}
+
+	var cancelled []string
+	e.Range(func(_, value any) bool {
+		p, ok := value.(*pod)
+		if !ok {
+			return true
+		}
+		p.mux.Lock()
+		defer p.mux.Unlock()
+		if p.terminated != nil {
+			return true
+		}
+		switch {
+		case p.started.IsZero():
+			// the job will be skipped by the worker
+			e.addProgress(3)
+		case e.restored:
+			// there is no worker that tracks the termination
+			e.addProgress(2)
+		default:
+			e.addProgress(1)
+		}
+		if !p.started.IsZero() && p.job != nil {
+			p.job.DeletePod()
+		}
+		p.terminate(statusCancelled)
+		c.prom.Cancelled(p.node, executionID)
+		cancelled = append(cancelled, p.node)
+		return true
+	})
+
+	l := c.log.WithValues("id", executionID, "reason", reason, "cancelled", len(cancelled))
+	e.saveCancellation(&cancellation{
+		Time:   time.Now(),
+		Reason: reason,
+		Nodes:  cancelled,
+	})
+	e.saveState()
+	l.Info("execution cancelled")
+	e.checkFinished()
+	return nil
 }
 
 func (c *controller) Has(node, executionID string) bool {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
 	if _, ok := c.nodes[node]; !ok {
 		return false
 	}
@@ -284,6 +795,8 @@ func (c *controller) Has(node, executionID string) bool {
 }
 
 func (c *controller) forID(id string) (*execution, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
 	e, ok := c.executions[id]
 	if !ok {
 		return nil, &ExecutionIDNotFoundError{Err: fmt.Errorf("execution with id: %q not found", id)}
@@ -291,15 +804,6 @@ func (c *controller) forID(id string) (*execution, error) {
 	return e, nil
 }
 
-func (c *controller) podForID(id, node string) (*pod, error) {
-	e, err := c.forID(id)
-	if err != nil {
-		return nil, err
-	}
-
-	return e.pod(node)
-}
-
 func (e *execution) pod(node string) (*pod, error) {
 	p, ok := e.Load(node)
 	if !ok {
@@ -313,16 +817,51 @@ func (e *execution) pod(node string) (*pod, error) {
 }
 
 type pod struct {
+	mux            sync.Mutex
 	node           string
+	job            Job
 	started        time.Time
 	terminated     *time.Time
 	reportReceived *time.Time
 	status         string
+	// files the names of the files uploaded by the pod
+	files []string
+	// attempt the current attempt of the job, starting with 0
+	attempt int
+	// done is closed when the pod is terminated
+	done chan struct{}
+	// retry is closed when the current attempt failed and the job is to be retried
+	retry chan struct{}
+}
+
+func newPod(node string, job Job) *pod {
+	return &pod{
+		node:  node,
+		job:   job,
+		done:  make(chan struct{}),
+		retry: make(chan struct{}),
+	}
+}
+
+// terminate mark the pod as terminated, returns false if the pod was already terminated.
+// The caller must hold the lock of the pod.
+func (p *pod) terminate(status string) bool {
+	if p.terminated != nil {
+		return false
+	}
+	t := time.Now()
+	p.terminated = &t
+	p.status = status
+	close(p.done)
+	return true
 }
 
 // Job interface.
 type Job interface {
 	CreatePod()
+	DeletePod()
+	// Retry get the job of the given attempt
+	Retry(attempt int) Job
 	ID() string
 	Node() string
 }
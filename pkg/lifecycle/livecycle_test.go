@@ -1,13 +1,18 @@
 package lifecycle
 
 import (
+	"encoding/json"
 	"errors"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/bakito/batch-job-controller/pkg/config"
 	"github.com/bakito/batch-job-controller/pkg/metrics"
@@ -60,7 +65,7 @@ var _ = Describe("lifecycle", func() {
 			_ = os.RemoveAll(c.reportDir)
 		})
 		It("should create an id and directory", func() {
-			id := c.NewExecution(0)
+			id := c.NewExecution("", 0)
 			Ω(id).ShouldNot(BeEmpty())
 			_, err := os.Stat(filepath.Join(repDir, id))
 			Ω(err).ShouldNot(HaveOccurred())
@@ -70,7 +75,7 @@ var _ = Describe("lifecycle", func() {
 			}
 		})
 		It("should create an id and directory and move the link", func() {
-			id1 := c.NewExecution(0)
+			id1 := c.NewExecution("", 0)
 			Ω(id1).ShouldNot(BeEmpty())
 			_, err := os.Stat(filepath.Join(repDir, id1))
 			Ω(err).ShouldNot(HaveOccurred())
@@ -78,11 +83,864 @@ var _ = Describe("lifecycle", func() {
 				_, err = os.Lstat(filepath.Join(repDir, "latest"))
 				Ω(err).ShouldNot(HaveOccurred())
 			}
-			id2 := c.NewExecution(0)
+			id2 := c.NewExecution("", 0)
 			Ω(id2).ShouldNot(BeEmpty())
+			Ω(id2).ShouldNot(Equal(id1))
 			_, err = os.Lstat(filepath.Join(repDir, id2))
 			Ω(err).ShouldNot(HaveOccurred())
 		})
+		It("should create unique and sortable ids within the same minute", func() {
+			// a format without time fields to always get the same timestamp
+			c.config.ExecutionIDFormat = "run"
+			id1 := c.NewExecution("", 0)
+			id2 := c.NewExecution("", 0)
+			id3 := c.NewExecution("", 0)
+			Ω(id1).Should(Equal("run"))
+			Ω(id2).Should(Equal(id1 + "-001"))
+			Ω(id3).Should(Equal(id1 + "-002"))
+			Ω(id1 < id2 && id2 < id3).Should(BeTrue())
+		})
+		It("should use the configured format", func() {
+			c.config.ExecutionIDFormat = "2006-01-02T15-04-05"
+			id := c.NewExecution("", 0)
+			Ω(id).Should(MatchRegexp(`^\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}$`))
+		})
+	})
+	Context("Schedules", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 1
+			cfg.Schedules = []config.Schedule{{Name: "nightly"}, {Name: "hourly"}}
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should prefix the execution id with the schedule name", func() {
+			id := c.NewExecution("nightly", 0)
+			Ω(id).Should(HavePrefix("nightly-"))
+
+			st, err := c.Execution(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Schedule).Should(Equal("nightly"))
+			state, err := c.loadState(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(state.Schedule).Should(Equal("nightly"))
+		})
+		It("should only prune the reports of the same schedule", func() {
+			old := time.Now().Add(-time.Hour)
+			for _, dir := range []string{"nightly-202001021504", "hourly-202001021504"} {
+				Ω(os.MkdirAll(filepath.Join(repDir, dir), 0o755)).ShouldNot(HaveOccurred())
+				Ω(os.Chtimes(filepath.Join(repDir, dir), old, old)).ShouldNot(HaveOccurred())
+			}
+
+			id := c.NewExecution("nightly", 0)
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			Ω(filepath.Join(repDir, "nightly-202001021504")).ShouldNot(BeADirectory())
+			Ω(filepath.Join(repDir, "hourly-202001021504")).Should(BeADirectory())
+			Ω(filepath.Join(repDir, id)).Should(BeADirectory())
+		})
+		It("should not prune hidden files", func() {
+			Ω(os.MkdirAll(repDir, 0o755)).ShouldNot(HaveOccurred())
+			hidden := filepath.Join(repDir, ".schedules.json")
+			Ω(os.WriteFile(hidden, []byte("{}"), 0o600)).ShouldNot(HaveOccurred())
+			old := time.Now().Add(-time.Hour)
+			Ω(os.Chtimes(hidden, old, old)).ShouldNot(HaveOccurred())
+
+			id := c.NewExecution("", 0)
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			Ω(hidden).Should(BeARegularFile())
+		})
+	})
+	Context("PartialExecution", func() {
+		var c *controller
+		// report create a report directory modified at the given time
+		report := func(id string, modified time.Time, partial bool) {
+			Ω(c.writeState(&executionState{ID: id, Partial: partial})).ShouldNot(HaveOccurred())
+			Ω(os.Chtimes(filepath.Join(repDir, id), modified, modified)).ShouldNot(HaveOccurred())
+		}
+		BeforeEach(func() {
+			cfg.PodPoolSize = 1
+			cfg.ReportHistory = 2
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should not replace the latest execution", func() {
+			id := c.NewExecution("", 0)
+			partialID := c.NewPartialExecution("", 1)
+
+			Ω(c.current.id).Should(Equal(id))
+			if runtime.GOOS != "windows" {
+				link, err := os.Readlink(filepath.Join(repDir, "latest"))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(link).Should(Equal(filepath.Join(repDir, id)))
+			}
+			st, err := c.Execution(partialID)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Partial).Should(BeTrue())
+			state, err := c.loadState(partialID)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(state.Partial).Should(BeTrue())
+		})
+		It("should not count partial executions for the report history", func() {
+			now := time.Now()
+			report("202001011504", now.Add(-4*time.Hour), true)
+			report("202001021504", now.Add(-3*time.Hour), false)
+			report("202001031504", now.Add(-2*time.Hour), false)
+			report("202001041504", now.Add(-time.Hour), true)
+
+			id := c.NewExecution("", 0)
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			// the partial execution is deleted with the execution before it
+			Ω(filepath.Join(repDir, "202001011504")).ShouldNot(BeADirectory())
+			Ω(filepath.Join(repDir, "202001021504")).ShouldNot(BeADirectory())
+			Ω(filepath.Join(repDir, "202001031504")).Should(BeADirectory())
+			Ω(filepath.Join(repDir, "202001041504")).Should(BeADirectory())
+			Ω(filepath.Join(repDir, id)).Should(BeADirectory())
+		})
+		It("should not prune the reports of a partial execution", func() {
+			now := time.Now()
+			report("202001021504", now.Add(-3*time.Hour), false)
+			report("202001031504", now.Add(-2*time.Hour), false)
+			report("202001041504", now.Add(-time.Hour), false)
+
+			id := c.NewPartialExecution("", 0)
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			Ω(filepath.Join(repDir, "202001021504")).Should(BeADirectory())
+		})
+	})
+	Context("executionIDValue", func() {
+		It("should return the numeric value of the id", func() {
+			Ω(executionIDValue("202001021504")).Should(Equal(202001021504.))
+		})
+		It("should add the sequence as fraction", func() {
+			Ω(executionIDValue("202001021504-002")).Should(BeNumerically("~", 202001021504.002, 0.0001))
+		})
+		It("should only use the digits", func() {
+			Ω(executionIDValue("2020-01-02T15-04")).Should(Equal(202001021504.))
+		})
+	})
+	Context("Restore", func() {
+		var (
+			c  *controller
+			id string
+		)
+		BeforeEach(func() {
+			cfg.PodPoolSize = 0
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+			id = "202001021504"
+
+			// persist the state of a previous controller
+			t := time.Now()
+			e := &execution{id: id, started: t, controller: c}
+			e.Store("node-a", &pod{node: "node-a", started: t, status: statusStarted, reportReceived: &t})
+			e.Store("node-lost", &pod{node: "node-lost", started: t, status: statusStarted})
+			e.saveState()
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should restore the execution from the state and the existing pods", func() {
+			err := c.Restore(id, []RestoredPod{jobPod("node-a", corev1.PodSucceeded), jobPod("node-b", corev1.PodRunning)})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(c.Has("node-a", id)).Should(BeTrue())
+			Ω(c.Has("node-b", id)).Should(BeTrue())
+			Ω(c.Has("node-lost", id)).Should(BeTrue())
+
+			e, err := c.forID(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			p, err := e.pod("node-a")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.terminated).ShouldNot(BeNil())
+			Ω(p.reportReceived).ShouldNot(BeNil())
+			Ω(p.status).Should(Equal(string(corev1.PodSucceeded)))
+
+			p, err = e.pod("node-b")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.terminated).Should(BeNil())
+			Ω(p.status).Should(Equal(statusStarted))
+
+			p, err = e.pod("node-lost")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.terminated).ShouldNot(BeNil())
+			Ω(p.status).Should(Equal(statusLost))
+		})
+		It("should keep tracking the restored pods", func() {
+			err := c.Restore(id, []RestoredPod{jobPod("node-b", corev1.PodRunning)})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(c.PodTerminated(id, "node-b", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+
+			st, err := c.loadState(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Pods).Should(HaveKey("node-b"))
+			Ω(st.Pods["node-b"].Terminated).ShouldNot(BeNil())
+			Ω(st.Pods["node-b"].Status).Should(Equal(string(corev1.PodSucceeded)))
+		})
+		It("should restore the pod of the current attempt of a node", func() {
+			t := time.Now()
+			e := &execution{id: id, started: t, controller: c}
+			e.Store("node-b", &pod{node: "node-b", started: t, status: statusStarted, attempt: 1})
+			e.saveState()
+
+			first := jobPod("node-b", corev1.PodFailed)
+			retry := jobPod("node-b", corev1.PodRunning)
+			retry.Attempt = 1
+			Ω(c.Restore(id, []RestoredPod{retry, first})).ShouldNot(HaveOccurred())
+
+			e, err := c.forID(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(e.jobs.Load()).Should(Equal(int64(1)))
+			Ω(e.getProgress()).Should(Equal("33%"))
+			Ω(e.finished.Load()).Should(BeNil())
+			p, err := e.pod("node-b")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.terminated).Should(BeNil())
+			Ω(p.attempt).Should(Equal(1))
+			Ω(p.job).Should(BeIdenticalTo(retry.Job))
+		})
+		It("should restore the pod of the highest attempt of a node without state", func() {
+			first := jobPod("node-b", corev1.PodFailed)
+			retry := jobPod("node-b", corev1.PodSucceeded)
+			retry.Attempt = 2
+			restored := currentAttempts([]RestoredPod{first, retry, jobPod("node-c", corev1.PodRunning)}, &executionState{})
+			Ω(restored).Should(HaveLen(2))
+			Ω(restored[0].Attempt).Should(Equal(2))
+			Ω(restored[1].Job.Node()).Should(Equal("node-c"))
+
+			st := &executionState{Pods: map[string]*podState{"node-b": {Attempt: 0}}}
+			restored = currentAttempts([]RestoredPod{retry, first}, st)
+			Ω(restored).Should(HaveLen(1))
+			Ω(restored[0].Attempt).Should(Equal(0))
+		})
+		It("should time out the restored pods exceeding the job timeout", func() {
+			c.config.JobTimeout = metav1.Duration{Duration: time.Hour}
+			running := jobPod("node-b", corev1.PodRunning)
+			running.Pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+			err := c.Restore(id, []RestoredPod{jobPod("node-a", corev1.PodSucceeded), running})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			e, err := c.forID(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Eventually(e.finished.Load).ShouldNot(BeNil())
+			p, err := e.pod("node-b")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.status).Should(Equal(statusTimedOut))
+			Ω(running.Job.(*testJob).deleted.Load()).Should(BeTrue())
+			Eventually(e.getProgress).Should(Equal("100%"))
+		})
+	})
+	Context("Cancel", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 1
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should delete running pods and skip pending jobs", func() {
+			id := c.NewExecution("", 2)
+			running := &testJob{id: id, node: "node-a"}
+			pending := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(running)).ShouldNot(HaveOccurred())
+			Eventually(running.created.Load).Should(BeTrue())
+			Ω(c.AddPod(pending)).ShouldNot(HaveOccurred())
+
+			Ω(c.Cancel(id, "test")).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			Ω(running.deleted.Load()).Should(BeTrue())
+			Consistently(pending.created.Load).Should(BeFalse())
+			Ω(pending.deleted.Load()).Should(BeFalse())
+
+			st, err := c.loadState(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Cancelled).Should(BeTrue())
+			Ω(st.Pods["node-a"].Status).Should(Equal(statusCancelled))
+			Ω(st.Pods["node-b"].Status).Should(Equal(statusCancelled))
+
+			b, err := os.ReadFile(filepath.Join(repDir, id, cancellationFileName))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(b)).Should(ContainSubstring(`"reason":"test"`))
+		})
+		It("should not cancel terminated pods", func() {
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+
+			Ω(c.Cancel(id, "test")).ShouldNot(HaveOccurred())
+			Ω(job.deleted.Load()).Should(BeFalse())
+		})
+		It("should not cancel a finished execution", func() {
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+
+			Ω(c.Cancel(id, "test")).Should(MatchError(ErrExecutionFinished))
+
+			st, err := c.loadState(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Cancelled).Should(BeFalse())
+			Ω(filepath.Join(repDir, id, cancellationFileName)).ShouldNot(BeAnExistingFile())
+		})
+		It("should return an error if the execution is not known", func() {
+			err := c.Cancel("foo", "test")
+			Ω(errors.Is(err, &ExecutionIDNotFoundError{})).Should(BeTrue())
+		})
+	})
+	Context("PodFailed", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 1
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should delete the pod, record the reason and free the worker", func() {
+			id := c.NewExecution("", 2)
+			failing := &testJob{id: id, node: "node-a"}
+			next := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(failing)).ShouldNot(HaveOccurred())
+			Eventually(failing.created.Load).Should(BeTrue())
+			Ω(c.AddPod(next)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			failed, err := c.PodFailed(id, "node-a", "ErrImagePull")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(failed).Should(BeTrue())
+			Ω(failing.deleted.Load()).Should(BeTrue())
+			Eventually(next.created.Load).Should(BeTrue())
+
+			failed, err = c.PodFailed(id, "node-a", "ErrImagePull")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(failed).Should(BeFalse())
+
+			Ω(c.PodTerminated(id, "node-b", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Eventually(c.getProgress).Should(Equal("100%"))
+
+			st, err := c.loadState(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Pods["node-a"].Status).Should(Equal("ErrImagePull"))
+		})
+		It("should return an error if the execution is not known", func() {
+			_, err := c.PodFailed("foo", "node-a", "ErrImagePull")
+			Ω(errors.Is(err, &ExecutionIDNotFoundError{})).Should(BeTrue())
+		})
+	})
+	Context("Status", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 1
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should return the status of the executions", func() {
+			id := c.NewExecution("", 2)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+			pending := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(pending)).ShouldNot(HaveOccurred())
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			executions := c.Executions()
+			Ω(executions).Should(HaveLen(1))
+			Ω(executions[0].ID).Should(Equal(id))
+			Ω(executions[0].Pods).Should(Equal(2))
+			Ω(executions[0].Terminated).Should(Equal(1))
+			Ω(executions[0].Progress).Should(Equal("50%"))
+			Ω(executions[0].Nodes).Should(BeNil())
+
+			st, err := c.Execution(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Nodes).Should(HaveLen(2))
+			Ω(st.Nodes["node-a"].Status).Should(Equal(string(corev1.PodSucceeded)))
+			Ω(st.Nodes["node-a"].Terminated).ShouldNot(BeNil())
+			Ω(st.Nodes["node-a"].Duration).ShouldNot(BeNil())
+			Ω(st.Nodes["node-b"].Terminated).Should(BeNil())
+
+			Eventually(pending.created.Load).Should(BeTrue())
+			Ω(c.PodTerminated(id, "node-b", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			// wait until the worker is finished
+			Eventually(c.Progress).Should(Equal("100%"))
+		})
+		It("should return an error if the execution is not known", func() {
+			_, err := c.Execution("foo")
+			Ω(errors.Is(err, &ExecutionIDNotFoundError{})).Should(BeTrue())
+		})
+	})
+	Context("AddPod", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 2
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should grow the execution if a job is added to the running execution", func() {
+			id := c.NewExecution("", 1)
+			jobA := &testJob{id: id, node: "node-a"}
+			jobB := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(jobA)).ShouldNot(HaveOccurred())
+			Ω(c.AddPod(jobB)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(jobA.created.Load).Should(BeTrue())
+			Eventually(jobB.created.Load).Should(BeTrue())
+
+			e, err := c.forID(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(e.jobs.Load()).Should(Equal(int64(2)))
+
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Ω(c.PodTerminated(id, "node-b", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Eventually(c.getProgress).Should(Equal("100%"))
+		})
+		It("should start a job added after all jobs were added", func() {
+			id := c.NewExecution("", 1)
+			jobA := &testJob{id: id, node: "node-a"}
+			jobB := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(jobA)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(jobA.created.Load).Should(BeTrue())
+
+			Ω(c.AddPod(jobB)).ShouldNot(HaveOccurred())
+			Eventually(jobB.created.Load).Should(BeTrue())
+			done, err := c.Finished(id)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Consistently(done, "50ms").ShouldNot(BeClosed())
+			Ω(c.PodTerminated(id, "node-b", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Eventually(done).Should(BeClosed())
+			Eventually(c.getProgress).Should(Equal("100%"))
+
+			// no job can be added to a finished execution
+			Ω(c.AddPod(&testJob{id: id, node: "node-c"})).Should(MatchError(ErrExecutionFinished))
+		})
+	})
+	Context("AwaitNodes", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 2
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should wait until the pods of the nodes are terminated", func() {
+			id := c.NewExecution("", 2)
+			jobA := &testJob{id: id, node: "node-a"}
+			jobB := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(jobA)).ShouldNot(HaveOccurred())
+			Ω(c.AddPod(jobB)).ShouldNot(HaveOccurred())
+			Eventually(jobA.created.Load).Should(BeTrue())
+			Eventually(jobB.created.Load).Should(BeTrue())
+
+			result := make(chan int)
+			go func() {
+				defer GinkgoRecover()
+				failed, err := c.AwaitNodes(id, []string{"node-a", "node-b"})
+				Ω(err).ShouldNot(HaveOccurred())
+				result <- failed
+			}()
+			c.ReportReceived(id, "node-a", nil, nil)
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Consistently(result, "50ms").ShouldNot(Receive())
+
+			Ω(c.PodTerminated(id, "node-b", corev1.PodFailed)).ShouldNot(HaveOccurred())
+			Eventually(result).Should(Receive(Equal(1)))
+		})
+		It("should return an error for an unknown node", func() {
+			id := c.NewExecution("", 0)
+			_, err := c.AwaitNodes(id, []string{"node-a"})
+			Ω(err).Should(HaveOccurred())
+		})
+		It("should return an error if the execution was cancelled", func() {
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+
+			Ω(c.Cancel(id, "test")).ShouldNot(HaveOccurred())
+			_, err := c.AwaitNodes(id, []string{"node-a"})
+			Ω(err).Should(MatchError(ErrExecutionCancelled))
+		})
+	})
+	Context("Summary", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 2
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should write the summary when all pods are terminated", func() {
+			id := c.NewExecution("", 2)
+			jobA := &testJob{id: id, node: "node-a"}
+			jobB := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(jobA)).ShouldNot(HaveOccurred())
+			Ω(c.AddPod(jobB)).ShouldNot(HaveOccurred())
+			Eventually(jobA.created.Load).Should(BeTrue())
+			Eventually(jobB.created.Load).Should(BeTrue())
+
+			c.ReportReceived(id, "node-a", nil, nil)
+			c.FileReceived(id, "node-a", "node-a-trace.log")
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Ω(c.PodTerminated(id, "node-b", corev1.PodFailed)).ShouldNot(HaveOccurred())
+
+			summaryFile := filepath.Join(repDir, id, summaryFileName)
+			Ω(summaryFile).ShouldNot(BeAnExistingFile())
+
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Ω(summaryFile).Should(BeAnExistingFile())
+			Eventually(c.getProgress).Should(Equal("100%"))
+
+			b, err := os.ReadFile(summaryFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			sum := &summary{}
+			Ω(json.Unmarshal(b, sum)).ShouldNot(HaveOccurred())
+			Ω(sum.ID).Should(Equal(id))
+			Ω(sum.Finished).ShouldNot(BeZero())
+			Ω(sum.Nodes).Should(HaveLen(2))
+			Ω(sum.Nodes["node-a"].Phase).Should(Equal(string(corev1.PodSucceeded)))
+			Ω(sum.Nodes["node-a"].ReportReceived).Should(BeTrue())
+			Ω(sum.Nodes["node-a"].ProcessingError).Should(BeFalse())
+			Ω(sum.Nodes["node-a"].Duration).ShouldNot(BeNil())
+			Ω(sum.Nodes["node-a"].Files).Should(ConsistOf("node-a-trace.log"))
+			Ω(sum.Nodes["node-b"].Phase).Should(Equal(string(corev1.PodFailed)))
+			Ω(sum.Nodes["node-b"].ReportReceived).Should(BeFalse())
+			Ω(sum.Nodes["node-b"].ProcessingError).Should(BeTrue())
+
+			st, err := c.Execution(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Finished).ShouldNot(BeNil())
+		})
+		It("should close the finished channel when all pods are terminated", func() {
+			id := c.NewExecution("", 1)
+			jobA := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(jobA)).ShouldNot(HaveOccurred())
+			Eventually(jobA.created.Load).Should(BeTrue())
+			done, err := c.Finished(id)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Consistently(done, "50ms").ShouldNot(BeClosed())
+
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Eventually(done).Should(BeClosed())
+
+			_, err = c.Finished("foo")
+			Ω(err).Should(HaveOccurred())
+		})
+		It("should count the nodes of the webhook payload", func() {
+			sum := &summary{
+				ID: "1",
+				Nodes: map[string]*nodeSummary{
+					"node-a": {Phase: string(corev1.PodSucceeded), ReportReceived: true},
+					"node-b": {Phase: string(corev1.PodSucceeded)},
+					"node-c": {Phase: statusTimedOut},
+				},
+			}
+			p := sum.payload(config.WebhookEventFinished)
+			Ω(p.Event).Should(Equal(config.WebhookEventFinished))
+			Ω(p.ExecutionID).Should(Equal("1"))
+			Ω(p.Nodes).Should(Equal(3))
+			Ω(p.Succeeded).Should(Equal(2))
+			Ω(p.Failed).Should(Equal(1))
+			Ω(p.MissingReport).Should(Equal(2))
+		})
+		It("should update the summary if a report is received late", func() {
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Eventually(c.getProgress).Should(Equal("100%"))
+
+			c.ReportReceived(id, "node-a", nil, nil)
+
+			b, err := os.ReadFile(filepath.Join(repDir, id, summaryFileName))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(b)).Should(ContainSubstring(`"reportReceived": true`))
+		})
+	})
+	Context("Retry", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 1
+			cfg.Retry = config.RetryPolicy{MaxAttempts: 3, Backoff: metav1.Duration{Duration: 10 * time.Millisecond}}
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should retry a failed job until it succeeds", func() {
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+
+			Ω(c.PodTerminated(id, "node-a", corev1.PodFailed)).ShouldNot(HaveOccurred())
+			Eventually(job.next.Load).ShouldNot(BeNil())
+			retry := job.next.Load()
+			Eventually(retry.created.Load).Should(BeTrue())
+			Ω(retry.attempt).Should(Equal(1))
+			Eventually(func() int { return c.Attempt(id, "node-a") }).Should(Equal(1))
+
+			// the report is missing
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Eventually(retry.next.Load).ShouldNot(BeNil())
+			last := retry.next.Load()
+			Eventually(last.created.Load).Should(BeTrue())
+
+			c.ReportReceived(id, "node-a", nil, nil)
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Eventually(c.getProgress).Should(Equal("100%"))
+
+			st, err := c.Execution(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Nodes["node-a"].Status).Should(Equal(string(corev1.PodSucceeded)))
+			Ω(st.Nodes["node-a"].Attempt).Should(Equal(2))
+			Ω(last.next.Load()).Should(BeNil())
+		})
+		It("should record the failure when the retries are exhausted", func() {
+			c.config.Retry.MaxAttempts = 2
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+
+			Ω(c.PodTerminated(id, "node-a", corev1.PodFailed)).ShouldNot(HaveOccurred())
+			Eventually(job.next.Load).ShouldNot(BeNil())
+			Eventually(job.next.Load().created.Load).Should(BeTrue())
+			Ω(c.PodTerminated(id, "node-a", corev1.PodFailed)).ShouldNot(HaveOccurred())
+			Eventually(c.getProgress).Should(Equal("100%"))
+
+			st, err := c.loadState(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Pods["node-a"].Status).Should(Equal(string(corev1.PodFailed)))
+			Ω(st.Pods["node-a"].Attempt).Should(Equal(1))
+			Ω(job.next.Load().next.Load()).Should(BeNil())
+		})
+		It("should not retry a cancelled job", func() {
+			c.config.Retry.Backoff = metav1.Duration{Duration: time.Hour}
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+
+			failed, err := c.PodFailed(id, "node-a", "CrashLoopBackOff")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(failed).Should(BeTrue())
+			Ω(job.deleted.Load()).Should(BeTrue())
+
+			Ω(c.Cancel(id, "test")).ShouldNot(HaveOccurred())
+			Eventually(c.getProgress).Should(Equal("100%"))
+			Ω(job.next.Load()).Should(BeNil())
+
+			st, err := c.loadState(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.Pods["node-a"].Status).Should(Equal(statusCancelled))
+		})
+	})
+	Context("Rerun", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 3
+			cfg.ReportHistory = 10
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should return the failed nodes of a finished execution and link the rerun", func() {
+			id := c.NewExecution("", 3)
+			jobA := &testJob{id: id, node: "node-a"}
+			jobB := &testJob{id: id, node: "node-b"}
+			jobC := &testJob{id: id, node: "node-c"}
+			Ω(c.AddPod(jobA)).ShouldNot(HaveOccurred())
+			Ω(c.AddPod(jobB)).ShouldNot(HaveOccurred())
+			Ω(c.AddPod(jobC)).ShouldNot(HaveOccurred())
+			Eventually(jobA.created.Load).Should(BeTrue())
+			Eventually(jobB.created.Load).Should(BeTrue())
+			Eventually(jobC.created.Load).Should(BeTrue())
+
+			c.ReportReceived(id, "node-a", nil, nil)
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+			Ω(c.PodTerminated(id, "node-b", corev1.PodFailed)).ShouldNot(HaveOccurred())
+			Ω(c.PodTerminated(id, "node-c", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+
+			_, err := c.FailedNodes(id)
+			Ω(err).Should(MatchError(ErrExecutionNotFinished))
+
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+			Eventually(c.getProgress).Should(Equal("100%"))
+			Ω(c.FailedNodes(id)).Should(Equal([]string{"node-b", "node-c"}))
+
+			rerunID := c.NewExecution("", 2)
+			Ω(c.LinkRerun(id, rerunID)).ShouldNot(HaveOccurred())
+
+			st, err := c.Execution(rerunID)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.RerunOf).Should(Equal(id))
+			st, err = c.Execution(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.RerunBy).Should(Equal([]string{rerunID}))
+
+			sum, err := c.loadSummary(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sum.RerunBy).Should(Equal([]string{rerunID}))
+			state, err := c.loadState(rerunID)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(state.RerunOf).Should(Equal(id))
+		})
+		It("should keep the original execution and the latest link on a rerun", func() {
+			c.reportHistory = 2 // 1+ for latest
+			id := c.NewExecution("", 0)
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			rerunID := c.NewPartialExecution("", 0)
+			Ω(c.LinkRerun(id, rerunID)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(rerunID)).ShouldNot(HaveOccurred())
+
+			Ω(filepath.Join(repDir, id)).Should(BeADirectory())
+			Ω(c.current.id).Should(Equal(id))
+			if runtime.GOOS != "windows" {
+				link, err := os.Readlink(filepath.Join(repDir, "latest"))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(link).Should(Equal(filepath.Join(repDir, id)))
+			}
+			st, err := c.Execution(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(st.RerunBy).Should(Equal([]string{rerunID}))
+		})
+		It("should read an execution that is not known anymore from its report directory", func() {
+			t := time.Now()
+			stored := &executionState{
+				ID: "202001021504",
+				Pods: map[string]*podState{
+					"node-a": {Terminated: &t, ReportReceived: &t, Status: string(corev1.PodSucceeded)},
+					"node-b": {Terminated: &t, Status: statusTimedOut},
+					"node-c": {Status: statusStarted},
+				},
+			}
+			Ω(c.writeState(stored)).ShouldNot(HaveOccurred())
+			Ω(c.saveSummary(&summary{ID: stored.ID})).ShouldNot(HaveOccurred())
+
+			Ω(c.FailedNodes(stored.ID)).Should(Equal([]string{"node-b", "node-c"}))
+
+			rerunID := c.NewExecution("", 2)
+			Ω(c.LinkRerun(stored.ID, rerunID)).ShouldNot(HaveOccurred())
+
+			state, err := c.loadState(stored.ID)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(state.RerunBy).Should(Equal([]string{rerunID}))
+			Ω(state.Pods).Should(HaveLen(3))
+			sum, err := c.loadSummary(stored.ID)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sum.RerunBy).Should(Equal([]string{rerunID}))
+		})
+		It("should return an error if there are no failed nodes", func() {
+			t := time.Now()
+			Ω(c.writeState(&executionState{
+				ID:   "202001021504",
+				Pods: map[string]*podState{"node-a": {Terminated: &t, ReportReceived: &t, Status: string(corev1.PodSucceeded)}},
+			})).ShouldNot(HaveOccurred())
+
+			_, err := c.FailedNodes("202001021504")
+			Ω(err).Should(MatchError(ErrNoFailedNodes))
+		})
+		It("should return an error if the execution is not known", func() {
+			_, err := c.FailedNodes("foo")
+			Ω(errors.Is(err, &ExecutionIDNotFoundError{})).Should(BeTrue())
+			_, err = c.FailedNodes("..")
+			Ω(errors.Is(err, &ExecutionIDNotFoundError{})).Should(BeTrue())
+		})
+	})
+	Context("JobTimeout", func() {
+		var c *controller
+		BeforeEach(func() {
+			cfg.PodPoolSize = 1
+			cfg.JobTimeout = metav1.Duration{Duration: 50 * time.Millisecond}
+			var ok bool
+			c, ok = NewController(cfg, pc).(*controller)
+			Ω(ok).Should(BeTrue())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(c.reportDir)
+		})
+		It("should delete the pod and free the worker if the timeout is exceeded", func() {
+			id := c.NewExecution("", 2)
+			hanging := &testJob{id: id, node: "node-a"}
+			next := &testJob{id: id, node: "node-b"}
+			Ω(c.AddPod(hanging)).ShouldNot(HaveOccurred())
+			Ω(c.AddPod(next)).ShouldNot(HaveOccurred())
+			Ω(c.AllAdded(id)).ShouldNot(HaveOccurred())
+
+			Eventually(hanging.deleted.Load).Should(BeTrue())
+			Eventually(next.created.Load).Should(BeTrue())
+			Eventually(c.getProgress).Should(Equal("100%"))
+
+			e, err := c.forID(id)
+			Ω(err).ShouldNot(HaveOccurred())
+			p, err := e.pod("node-a")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(p.toState().Status).Should(Equal(statusTimedOut))
+		})
+		It("should not delete the pod if terminated in time", func() {
+			id := c.NewExecution("", 1)
+			job := &testJob{id: id, node: "node-a"}
+			Ω(c.AddPod(job)).ShouldNot(HaveOccurred())
+			Eventually(job.created.Load).Should(BeTrue())
+			Ω(c.PodTerminated(id, "node-a", corev1.PodSucceeded)).ShouldNot(HaveOccurred())
+
+			Consistently(job.deleted.Load, 100*time.Millisecond).Should(BeFalse())
+		})
 	})
 	Context("ExecutionIDNotFound", func() {
 		It("error should match", func() {
@@ -92,3 +950,45 @@ var _ = Describe("lifecycle", func() {
 		})
 	})
 })
+
+func jobPod(node string, phase corev1.PodPhase) RestoredPod {
+	return RestoredPod{
+		Job: &testJob{node: node},
+		Pod: corev1.Pod{
+			Spec:   corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{Phase: phase},
+		},
+	}
+}
+
+type testJob struct {
+	id      string
+	node    string
+	attempt int
+	created atomic.Bool
+	deleted atomic.Bool
+	// next the job of the next attempt
+	next atomic.Pointer[testJob]
+}
+
+func (j *testJob) Retry(attempt int) Job {
+	next := &testJob{id: j.id, node: j.node, attempt: attempt}
+	j.next.Store(next)
+	return next
+}
+
+func (j *testJob) CreatePod() {
+	j.created.Store(true)
+}
+
+func (j *testJob) DeletePod() {
+	j.deleted.Store(true)
+}
+
+func (j *testJob) ID() string {
+	return j.id
+}
+
+func (j *testJob) Node() string {
+	return j.node
+}
@@ -0,0 +1,104 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FailedNodes the nodes of a finished execution whose pod did not succeed or did not send a report.
+// Executions that are not known anymore, e.g. after a restart of the controller, are read from their report directory.
+func (c *controller) FailedNodes(executionID string) ([]string, error) {
+	var pods map[string]*podState
+	if e, err := c.forID(executionID); err == nil {
+		if e.finished.Load() == nil {
+			return nil, ErrExecutionNotFinished
+		}
+		pods = make(map[string]*podState)
+		e.Range(func(_, value any) bool {
+			if p, ok := value.(*pod); ok {
+				pods[p.node] = p.toState()
+			}
+			return true
+		})
+	} else {
+		st, err := c.loadStoredState(executionID)
+		if err != nil {
+			return nil, err
+		}
+		pods = st.Pods
+	}
+
+	var nodes []string
+	for node, ps := range pods {
+		if ps.Status != string(corev1.PodSucceeded) || ps.ReportReceived == nil {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, ErrNoFailedNodes
+	}
+	slices.Sort(nodes)
+	return nodes, nil
+}
+
+// LinkRerun link an execution as rerun of the failed nodes of the original execution.
+// The link is recorded in the state and summary of both executions.
+func (c *controller) LinkRerun(originalID, executionID string) error {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return err
+	}
+	e.stateMux.Lock()
+	e.rerunOf = originalID
+	e.stateMux.Unlock()
+	e.saveState()
+
+	if o, err := c.forID(originalID); err == nil {
+		o.stateMux.Lock()
+		o.rerunBy = append(o.rerunBy, executionID)
+		o.stateMux.Unlock()
+		o.saveState()
+		o.updateSummary()
+		return nil
+	}
+
+	// the original execution is only known by its report directory
+	st, err := c.loadStoredState(originalID)
+	if err != nil {
+		return err
+	}
+	st.RerunBy = append(st.RerunBy, executionID)
+	if err := c.writeState(st); err != nil {
+		return err
+	}
+	sum, err := c.loadSummary(originalID)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	sum.RerunBy = append(sum.RerunBy, executionID)
+	return c.saveSummary(sum)
+}
+
+// loadStoredState load the persisted state of an execution that is not known by the controller.
+func (c *controller) loadStoredState(executionID string) (*executionState, error) {
+	notFound := &ExecutionIDNotFoundError{Err: fmt.Errorf("execution with id: %q not found", executionID)}
+	if executionID != filepath.Base(executionID) || strings.HasPrefix(executionID, ".") {
+		return nil, notFound
+	}
+	if _, err := os.Stat(c.config.ReportFileName(executionID, stateFileName)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	return c.loadState(executionID)
+}
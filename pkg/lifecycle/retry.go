@@ -0,0 +1,76 @@
+package lifecycle
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// retrying returns true if a retry of the failed attempt is pending. The caller must hold the lock of the pod.
+func (p *pod) retrying() bool {
+	return p.status == statusRetrying
+}
+
+// scheduleRetry signal the worker to retry the failed attempt of the pod if the retry policy allows it.
+// Returns false if the job is not to be retried. The caller must hold the lock of the pod.
+func (e *execution) scheduleRetry(p *pod) bool {
+	if e.restored || e.cancelled.Load() || p.job == nil {
+		// restored executions have no worker to retry the job
+		return false
+	}
+	if p.attempt+1 >= e.controller.config.Retry.MaxAttempts {
+		return false
+	}
+	p.status = statusRetrying
+	close(p.retry)
+	return true
+}
+
+// retry start the next attempt of a failed job after the backoff.
+// Returns false if the pod was terminated in the meantime, e.g. by a cancellation.
+func (e *execution) retry(p *pod, l logr.Logger) bool {
+	p.mux.Lock()
+	attempt := p.attempt + 1
+	p.mux.Unlock()
+
+	backoff := e.controller.config.Retry.BackoffFor(attempt)
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+		return false
+	case <-timer.C:
+	}
+
+	p.mux.Lock()
+	if p.terminated != nil {
+		p.mux.Unlock()
+		return false
+	}
+	p.attempt = attempt
+	p.retry = make(chan struct{})
+	p.job = p.job.Retry(attempt)
+	p.job.CreatePod()
+	p.status = statusStarted
+	p.reportReceived = nil
+	p.mux.Unlock()
+
+	e.saveState()
+	l.WithValues("jobID", e.id, "nodeName", p.node, "attempt", attempt, "backoff", backoff).Info("retry job")
+	return true
+}
+
+// Attempt the current attempt of the job of a node, 0 if unknown.
+func (c *controller) Attempt(executionID, node string) int {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return 0
+	}
+	p, err := e.pod(node)
+	if err != nil {
+		return 0
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.attempt
+}
@@ -0,0 +1,344 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/bakito/batch-job-controller/pkg/config"
+)
+
+const (
+	// stateFileName name of the file the execution state is persisted to, it is hidden to never collide with a node name.
+	stateFileName = ".state.json"
+	// cancellationFileName name of the file the cancellation of an execution is recorded to.
+	cancellationFileName = "cancellation.json"
+
+	statusStarted   = "Started"
+	statusLost      = "Lost"
+	statusCancelled = "Cancelled"
+	statusTimedOut  = "TimedOut"
+	statusRetrying  = "Retrying"
+)
+
+// executionState the persisted state of an execution.
+type executionState struct {
+	ID        string    `json:"id"`
+	Started   time.Time `json:"started"`
+	Cancelled bool      `json:"cancelled,omitempty"`
+	Schedule  string    `json:"schedule,omitempty"`
+	// Partial is true for an execution of some targets of the schedule
+	Partial bool `json:"partial,omitempty"`
+	// RerunOf the id of the execution whose failed nodes are rerun by this execution
+	RerunOf string `json:"rerunOf,omitempty"`
+	// RerunBy the ids of the executions rerunning the failed nodes of this execution
+	RerunBy []string             `json:"rerunBy,omitempty"`
+	Pods    map[string]*podState `json:"pods"`
+}
+
+// cancellation record of a cancelled execution.
+type cancellation struct {
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+	// Nodes the nodes whose jobs were cancelled
+	Nodes []string `json:"nodes"`
+}
+
+// RestoredPod a job pod that survived a restart of the controller.
+type RestoredPod struct {
+	Job Job
+	Pod corev1.Pod
+	// Attempt the attempt of the job pod, 0 for the first attempt
+	Attempt int
+}
+
+// podState the persisted state of a pod.
+type podState struct {
+	Started        *time.Time `json:"started,omitempty"`
+	Terminated     *time.Time `json:"terminated,omitempty"`
+	ReportReceived *time.Time `json:"reportReceived,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	Files          []string   `json:"files,omitempty"`
+	Attempt        int        `json:"attempt,omitempty"`
+}
+
+func (ps *podState) toPod(node string, job Job) *pod {
+	p := newPod(node, job)
+	p.reportReceived = ps.ReportReceived
+	p.status = ps.Status
+	p.files = ps.Files
+	p.attempt = ps.Attempt
+	if ps.Started != nil {
+		p.started = *ps.Started
+	}
+	if ps.Terminated != nil {
+		p.terminated = ps.Terminated
+		close(p.done)
+	}
+	return p
+}
+
+func (p *pod) toState() *podState {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	ps := &podState{
+		Terminated:     p.terminated,
+		ReportReceived: p.reportReceived,
+		Status:         p.status,
+		Files:          slices.Clone(p.files),
+		Attempt:        p.attempt,
+	}
+	if !p.started.IsZero() {
+		started := p.started
+		ps.Started = &started
+	}
+	return ps
+}
+
+// saveState persist the current state of the execution into its report directory.
+func (e *execution) saveState() {
+	e.stateMux.Lock()
+	defer e.stateMux.Unlock()
+
+	st := &executionState{
+		ID:        e.id,
+		Started:   e.started,
+		Cancelled: e.cancelled.Load(),
+		Schedule:  e.schedule,
+		Partial:   e.partial,
+		RerunOf:   e.rerunOf,
+		RerunBy:   slices.Clone(e.rerunBy),
+		Pods:      make(map[string]*podState),
+	}
+	e.Range(func(key, value any) bool {
+		if p, ok := value.(*pod); ok {
+			st.Pods[p.node] = p.toState()
+		}
+		return true
+	})
+
+	if err := e.controller.writeState(st); err != nil {
+		e.controller.log.WithValues("id", e.id).Error(err, "could not write execution state")
+	}
+}
+
+// writeState write the state into the report directory of its execution.
+func (c *controller) writeState(st *executionState) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := c.config.MkReportDir(st.ID); err != nil {
+		return err
+	}
+	fileName := c.config.ReportFileName(st.ID, stateFileName)
+	// write to a temp file first to never leave a partially written state behind
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+// saveCancellation record the cancellation into the report directory of the execution.
+func (e *execution) saveCancellation(c *cancellation) {
+	l := e.controller.log.WithValues("id", e.id)
+	b, err := json.Marshal(c)
+	if err != nil {
+		l.Error(err, "could not marshal cancellation")
+		return
+	}
+	if err := e.controller.config.MkReportDir(e.id); err != nil {
+		l.Error(err, "could not create report directory")
+		return
+	}
+	if err := os.WriteFile(e.controller.config.ReportFileName(e.id, cancellationFileName), b, 0o600); err != nil {
+		l.Error(err, "could not write cancellation")
+	}
+}
+
+// loadState load the persisted state of an execution. An empty state is returned if none was persisted.
+func (c *controller) loadState(executionID string) (*executionState, error) {
+	st := &executionState{
+		ID:   executionID,
+		Pods: make(map[string]*podState),
+	}
+	b, err := os.ReadFile(c.config.ReportFileName(executionID, stateFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return st, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	if st.Pods == nil {
+		st.Pods = make(map[string]*podState)
+	}
+	return st, nil
+}
+
+// Restore rebuild an execution from its persisted state and the job pods still existing.
+func (c *controller) Restore(executionID string, pods []RestoredPod) error {
+	if _, err := c.forID(executionID); err == nil {
+		// execution is already known
+		return nil
+	}
+
+	st, err := c.loadState(executionID)
+	if err != nil {
+		return err
+	}
+
+	// no more jobs will be dispatched for a restored execution
+	jobChan := make(chan Job)
+	close(jobChan)
+	schedule := st.Schedule
+	if schedule == "" {
+		schedule = c.config.ScheduleOf(executionID)
+	}
+	e := &execution{
+		id:         executionID,
+		schedule:   schedule,
+		started:    st.Started,
+		jobChan:    jobChan,
+		controller: c,
+		restored:   true,
+		partial:    st.Partial,
+		done:       make(chan struct{}),
+	}
+	e.cancelled.Store(st.Cancelled)
+	e.allAdded.Store(true)
+	e.rerunOf = st.RerunOf
+	e.rerunBy = st.RerunBy
+	if e.started.IsZero() {
+		e.started = time.Now()
+	}
+
+	pods = currentAttempts(pods, st)
+
+	var progress uint64
+	for i := range pods {
+		node := pods[i].Job.Node()
+		p := newPod(node, pods[i].Job)
+		if ps, ok := st.Pods[node]; ok {
+			p = ps.toPod(node, pods[i].Job)
+		}
+		p.attempt = pods[i].Attempt
+		if p.started.IsZero() {
+			p.started = pods[i].Pod.CreationTimestamp.Time
+		}
+		if p.status == "" {
+			p.status = statusStarted
+		}
+		progress++
+		if p.terminated != nil {
+			progress += 2
+		}
+		e.Store(node, p)
+	}
+
+	// pods that were persisted but do not exist anymore can not be tracked any further
+	for node, ps := range st.Pods {
+		if _, ok := e.Load(node); ok {
+			continue
+		}
+		p := ps.toPod(node, nil)
+		if p.terminate(statusLost) {
+			if p.reportReceived == nil {
+				c.prom.ProcessingFinished(node, executionID, true)
+			}
+		}
+		progress += 3
+		e.Store(node, p)
+	}
+
+	var nodes []string
+	e.Range(func(key, _ any) bool {
+		if node, ok := key.(string); ok {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	e.jobs.Store(int64(len(nodes)))
+	e.added.Store(int64(len(nodes)))
+	e.progress.Store(progress)
+
+	c.mux.Lock()
+	c.executions[executionID] = e
+	if !e.partial {
+		c.current = e
+	}
+	for _, node := range nodes {
+		c.nodes[node] = true
+	}
+	c.mux.Unlock()
+
+	if !e.partial {
+		c.prom.Pods(schedule, float64(len(nodes)))
+		c.prom.ExecutionStarted(schedule, executionIDValue(strings.TrimPrefix(executionID, config.ExecutionIDPrefix(schedule))))
+	}
+
+	// pods that terminated while the controller was not running
+	for i := range pods {
+		phase := pods[i].Pod.Status.Phase
+		if phase == corev1.PodSucceeded || phase == corev1.PodFailed {
+			if err := c.PodTerminated(executionID, pods[i].Job.Node(), phase); err != nil {
+				return err
+			}
+		}
+	}
+	e.saveState()
+
+	if timeout := c.config.JobTimeout.Duration; timeout > 0 {
+		e.Range(func(_, value any) bool {
+			if p, ok := value.(*pod); ok {
+				p.mux.Lock()
+				running := p.terminated == nil
+				p.mux.Unlock()
+				if running {
+					go e.watchTimeout(p, timeout)
+				}
+			}
+			return true
+		})
+	}
+
+	c.log.WithValues("id", executionID, "pods", len(pods), "progress", e.getProgress()).Info("restored execution")
+	e.checkFinished()
+	return nil
+}
+
+// currentAttempts get the pod of the current attempt of each node. This is the pod of the persisted attempt, or the pod
+// of the highest attempt if there is none. The pods of previous attempts are not tracked any further.
+func currentAttempts(pods []RestoredPod, st *executionState) []RestoredPod {
+	current := make(map[string]int)
+	for i := range pods {
+		node := pods[i].Job.Node()
+		c, ok := current[node]
+		if !ok || isLaterAttempt(pods[i].Attempt, pods[c].Attempt, st.Pods[node]) {
+			current[node] = i
+		}
+	}
+	var restored []RestoredPod
+	for i := range pods {
+		if current[pods[i].Job.Node()] == i {
+			restored = append(restored, pods[i])
+		}
+	}
+	return restored
+}
+
+// isLaterAttempt returns true if the attempt is to be restored instead of the other attempt of the same node.
+func isLaterAttempt(attempt, other int, ps *podState) bool {
+	if ps != nil && (attempt == ps.Attempt) != (other == ps.Attempt) {
+		return attempt == ps.Attempt
+	}
+	return attempt > other
+}
@@ -0,0 +1,148 @@
+package lifecycle
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExecutionStatus the current status of an execution.
+type ExecutionStatus struct {
+	ID string `json:"id"`
+	// Schedule the name of the schedule of the execution, empty for the default schedule
+	Schedule string    `json:"schedule,omitempty"`
+	Started  time.Time `json:"started"`
+	// Finished the time all pods of the execution were terminated
+	Finished  *time.Time `json:"finished,omitempty"`
+	Cancelled bool       `json:"cancelled,omitempty"`
+	// Restored is true if the execution was rebuilt after a restart of the controller
+	Restored bool `json:"restored,omitempty"`
+	// Partial is true for an execution of some targets of the schedule, e.g. of a node that became ready
+	Partial bool `json:"partial,omitempty"`
+	// RerunOf the id of the execution whose failed nodes are rerun by this execution
+	RerunOf string `json:"rerunOf,omitempty"`
+	// RerunBy the ids of the executions rerunning the failed nodes of this execution
+	RerunBy []string `json:"rerunBy,omitempty"`
+	// Progress the share of terminated pods in percent
+	Progress string `json:"progress"`
+	// Pods the number of pods of the execution
+	Pods int `json:"pods"`
+	// Terminated the number of terminated pods of the execution
+	Terminated int `json:"terminated"`
+	// Nodes the status of the pod per node
+	Nodes map[string]*NodeStatus `json:"nodes,omitempty"`
+}
+
+// NodeStatus the status of the pod of a node.
+type NodeStatus struct {
+	Started        *time.Time `json:"started,omitempty"`
+	Terminated     *time.Time `json:"terminated,omitempty"`
+	ReportReceived *time.Time `json:"reportReceived,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	// Files the names of the files uploaded by the pod
+	Files []string `json:"files,omitempty"`
+	// Attempt the current attempt of the job, starting with 0
+	Attempt int `json:"attempt,omitempty"`
+	// Duration the duration of the pod, until now if it is still running
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// Progress the overall progress of the current execution.
+func (c *controller) Progress() string {
+	return c.getProgress()
+}
+
+// Executions the status of all known executions, the latest first.
+func (c *controller) Executions() []*ExecutionStatus {
+	c.mux.RLock()
+	executions := make([]*execution, 0, len(c.executions))
+	for _, e := range c.executions {
+		executions = append(executions, e)
+	}
+	c.mux.RUnlock()
+
+	var statuses []*ExecutionStatus
+	for _, e := range executions {
+		statuses = append(statuses, e.status(false))
+	}
+	slices.SortFunc(statuses, func(a, b *ExecutionStatus) int {
+		if c := b.Started.Compare(a.Started); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+	return statuses
+}
+
+// Execution the status of the execution with the given id including the status of its nodes.
+func (c *controller) Execution(executionID string) (*ExecutionStatus, error) {
+	e, err := c.forID(executionID)
+	if err != nil {
+		return nil, err
+	}
+	return e.status(true), nil
+}
+
+func (e *execution) status(withNodes bool) *ExecutionStatus {
+	es := &ExecutionStatus{
+		ID:        e.id,
+		Schedule:  e.schedule,
+		Started:   e.started,
+		Finished:  e.finished.Load(),
+		Cancelled: e.cancelled.Load(),
+		Restored:  e.restored,
+		Partial:   e.partial,
+	}
+	e.stateMux.Lock()
+	es.RerunOf = e.rerunOf
+	es.RerunBy = slices.Clone(e.rerunBy)
+	e.stateMux.Unlock()
+	if withNodes {
+		es.Nodes = make(map[string]*NodeStatus)
+	}
+
+	e.Range(func(_, value any) bool {
+		p, ok := value.(*pod)
+		if !ok {
+			return true
+		}
+		ns := p.toNodeStatus()
+		es.Pods++
+		if ns.Terminated != nil {
+			es.Terminated++
+		}
+		if withNodes {
+			es.Nodes[p.node] = ns
+		}
+		return true
+	})
+	if es.Pods > 0 {
+		es.Progress = fmt.Sprintf("%.f%%", 100*float64(es.Terminated)/float64(es.Pods))
+	} else {
+		es.Progress = "0%"
+	}
+	return es
+}
+
+func (p *pod) toNodeStatus() *NodeStatus {
+	ps := p.toState()
+	ns := &NodeStatus{
+		Started:        ps.Started,
+		Terminated:     ps.Terminated,
+		ReportReceived: ps.ReportReceived,
+		Status:         ps.Status,
+		Files:          ps.Files,
+		Attempt:        ps.Attempt,
+	}
+	if ns.Started != nil {
+		end := time.Now()
+		if ns.Terminated != nil {
+			end = *ns.Terminated
+		}
+		ns.Duration = &metav1.Duration{Duration: end.Sub(*ns.Started).Round(time.Millisecond)}
+	}
+	return ns
+}
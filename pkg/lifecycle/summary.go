@@ -0,0 +1,175 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/bakito/batch-job-controller/pkg/config"
+	"github.com/bakito/batch-job-controller/pkg/webhook"
+)
+
+// summaryFileName name of the file the summary of a finished execution is written to.
+const summaryFileName = "summary.json"
+
+// summary of a finished execution.
+type summary struct {
+	ID        string    `json:"id"`
+	Schedule  string    `json:"schedule,omitempty"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Cancelled bool      `json:"cancelled,omitempty"`
+	// RerunOf the id of the execution whose failed nodes are rerun by this execution
+	RerunOf string `json:"rerunOf,omitempty"`
+	// RerunBy the ids of the executions rerunning the failed nodes of this execution
+	RerunBy []string                `json:"rerunBy,omitempty"`
+	Nodes   map[string]*nodeSummary `json:"nodes"`
+}
+
+// nodeSummary summary of the pod of a node.
+type nodeSummary struct {
+	// Phase the final phase or failure reason of the pod
+	Phase          string           `json:"phase"`
+	Started        *time.Time       `json:"started,omitempty"`
+	Terminated     *time.Time       `json:"terminated,omitempty"`
+	Duration       *metav1.Duration `json:"duration,omitempty"`
+	ReportReceived bool             `json:"reportReceived"`
+	// ProcessingError is true if the pod was not successful or did not send a report
+	ProcessingError bool     `json:"processingError"`
+	Files           []string `json:"files,omitempty"`
+	// Attempts the number of attempts of the job
+	Attempts int `json:"attempts"`
+}
+
+// checkFinished mark the execution as finished and write its summary once all jobs were added and all pods terminated.
+func (e *execution) checkFinished() {
+	if !e.allAdded.Load() {
+		return
+	}
+	// no job may be added while the execution is finished
+	e.addMux.Lock()
+	terminated := true
+	e.Range(func(_, value any) bool {
+		if p, ok := value.(*pod); ok {
+			p.mux.Lock()
+			terminated = p.terminated != nil
+			p.mux.Unlock()
+		}
+		return terminated
+	})
+	t := time.Now()
+	if !terminated || !e.finished.CompareAndSwap(nil, &t) {
+		// not yet or already finished
+		e.addMux.Unlock()
+		return
+	}
+	e.addMux.Unlock()
+	close(e.done)
+	sum := e.writeSummary()
+	e.controller.log.WithValues("id", e.id, "duration", t.Sub(e.started).String()).Info("execution finished")
+
+	p := sum.payload(config.WebhookEventFinished)
+	e.controller.webhooks.Notify(p)
+	if p.Failed > 0 || p.MissingReport > 0 {
+		p.Event = config.WebhookEventFailed
+		e.controller.webhooks.Notify(p)
+	}
+}
+
+// payload create the webhook payload of the summary.
+func (s *summary) payload(event string) webhook.Payload {
+	finished := s.Finished
+	p := webhook.Payload{
+		Event:       event,
+		ExecutionID: s.ID,
+		Started:     s.Started,
+		Finished:    &finished,
+		Cancelled:   s.Cancelled,
+		Nodes:       len(s.Nodes),
+	}
+	for _, n := range s.Nodes {
+		if n.Phase == string(corev1.PodSucceeded) {
+			p.Succeeded++
+		} else {
+			p.Failed++
+		}
+		if !n.ReportReceived {
+			p.MissingReport++
+		}
+	}
+	return p
+}
+
+// updateSummary rewrite the summary of a finished execution, e.g. if a report is received after the pod terminated.
+func (e *execution) updateSummary() {
+	if e.finished.Load() != nil {
+		e.writeSummary()
+	}
+}
+
+// writeSummary write the summary of the execution into its report directory.
+func (e *execution) writeSummary() *summary {
+	e.stateMux.Lock()
+	defer e.stateMux.Unlock()
+
+	sum := &summary{
+		ID:        e.id,
+		Schedule:  e.schedule,
+		Started:   e.started,
+		Finished:  *e.finished.Load(),
+		Cancelled: e.cancelled.Load(),
+		RerunOf:   e.rerunOf,
+		RerunBy:   slices.Clone(e.rerunBy),
+		Nodes:     make(map[string]*nodeSummary),
+	}
+	e.Range(func(_, value any) bool {
+		if p, ok := value.(*pod); ok {
+			ns := p.toNodeStatus()
+			sum.Nodes[p.node] = &nodeSummary{
+				Phase:           ns.Status,
+				Started:         ns.Started,
+				Terminated:      ns.Terminated,
+				Duration:        ns.Duration,
+				ReportReceived:  ns.ReportReceived != nil,
+				ProcessingError: ns.Status != string(corev1.PodSucceeded) || ns.ReportReceived == nil,
+				Files:           ns.Files,
+				Attempts:        ns.Attempt + 1,
+			}
+		}
+		return true
+	})
+
+	if err := e.controller.saveSummary(sum); err != nil {
+		e.controller.log.WithValues("id", e.id).Error(err, "could not write execution summary")
+	}
+	return sum
+}
+
+// saveSummary write the summary into the report directory of its execution.
+func (c *controller) saveSummary(sum *summary) error {
+	b, err := json.MarshalIndent(sum, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := c.config.MkReportDir(sum.ID); err != nil {
+		return err
+	}
+	return os.WriteFile(c.config.ReportFileName(sum.ID, summaryFileName), b, 0o600)
+}
+
+// loadSummary load the summary of a finished execution from its report directory.
+func (c *controller) loadSummary(executionID string) (*summary, error) {
+	b, err := os.ReadFile(c.config.ReportFileName(executionID, summaryFileName))
+	if err != nil {
+		return nil, err
+	}
+	sum := &summary{}
+	if err := json.Unmarshal(b, sum); err != nil {
+		return nil, err
+	}
+	return sum, nil
+}
@@ -2,6 +2,41 @@ package lifecycle
 
 import "errors"
 
+var (
+	// ErrExecutionRunning an execution is already running.
+	ErrExecutionRunning = errors.New("an execution is already running")
+	// ErrNoMatchingNodes no node matches the trigger options.
+	ErrNoMatchingNodes = errors.New("no matching nodes found")
+	// ErrExecutionNotFinished the execution still has pods that are not terminated.
+	ErrExecutionNotFinished = errors.New("the execution is not finished yet")
+	// ErrNoFailedNodes the execution has no failed nodes to rerun.
+	ErrNoFailedNodes = errors.New("the execution has no failed nodes")
+	// ErrExecutionFinished the execution is already finished.
+	ErrExecutionFinished = errors.New("the execution is already finished")
+	// ErrExecutionCancelled the execution was cancelled.
+	ErrExecutionCancelled = errors.New("the execution was cancelled")
+	// ErrUnknownSchedule no schedule with the given name is configured.
+	ErrUnknownSchedule = errors.New("unknown schedule")
+)
+
+// TriggerOptions limit an on demand execution.
+type TriggerOptions struct {
+	// Nodes the names of the nodes to run the execution on
+	Nodes []string `json:"nodes,omitempty"`
+	// Selector a label selector to filter the nodes to run the execution on
+	Selector string `json:"selector,omitempty"`
+	// Schedule the name of the schedule to start the execution of, default is the first schedule
+	Schedule string `json:"schedule,omitempty"`
+	// RerunOf the id of the execution whose failed nodes are rerun, set by the rerun api only
+	RerunOf string `json:"-"`
+}
+
+// Trigger starts executions on demand.
+type Trigger interface {
+	// Trigger start a new execution and return its id
+	Trigger(opts TriggerOptions) (string, error)
+}
+
 // ExecutionIDNotFoundError custom error.
 type ExecutionIDNotFoundError struct {
 	Err error
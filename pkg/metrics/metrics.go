@@ -2,7 +2,9 @@ package metrics
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
+	"time"
 
 	prom "github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -17,12 +19,21 @@ const (
 	labelExecutionID = "executionID"
 	labelPrefix      = "prefix"
 	labelCron        = "cron"
+	labelReason      = "failure_reason"
+	labelSchedule    = "schedule"
+	labelWindow      = "window"
+	labelAction      = "action"
 
 	versionMetric = "com_github_bakito_batch_job_controller"
 
 	procErrorHelp = "Node with processing error, 1: has error / 0: no error"
 	versionHelp   = "information about github.com/bakito/batch-job-controller"
 	podsHelp      = "The number of pods started for the last execution"
+	cancelledHelp = "Node with a cancelled job, 1: cancelled"
+	failedHelp    = "Node with a job pod that failed before terminating, 1: failed"
+	nextHelp      = "The unix timestamp of the next scheduled execution"
+	blockedHelp   = "The number of scheduled executions blocked by a maintenance window"
+	canaryHelp    = "The number of executions aborted by a failed canary phase"
 
 	currentExecutionHelp = "The current execution ID"
 	durationHelp         = "Execution Duration in milliseconds"
@@ -31,6 +42,11 @@ const (
 	procErrorMetric        = "processing"
 	durationMetric         = "duration"
 	podsMetric             = "pods"
+	cancelledMetric        = "execution_cancelled"
+	failedMetric           = "execution_failed"
+	nextMetric             = "next_execution_timestamp"
+	blockedMetric          = "maintenance_blocked_total"
+	canaryMetric           = "canary_failed_total"
 )
 
 // Collector struct.
@@ -39,7 +55,12 @@ type Collector struct {
 	executionIDGauge *prom.GaugeVec
 	procErrorGauge   *executionIDMetric
 	durationGauge    *executionIDMetric
+	cancelledGauge   *executionIDMetric
+	failedGauge      *executionIDMetric
 	podsGauge        *prom.GaugeVec
+	nextGauge        *prom.GaugeVec
+	blockedCounter   *prom.CounterVec
+	canaryCounter    *prom.CounterVec
 	versionGauge     *prom.GaugeVec
 	namespace        string
 	latestMetric     bool
@@ -49,10 +70,15 @@ type Collector struct {
 func (c *Collector) Describe(ch chan<- *prom.Desc) {
 	c.executionIDGauge.Describe(ch)
 	c.podsGauge.Describe(ch)
+	c.nextGauge.Describe(ch)
+	c.blockedCounter.Describe(ch)
+	c.canaryCounter.Describe(ch)
 	c.versionGauge.Describe(ch)
 
 	c.procErrorGauge.describe(ch)
 	c.durationGauge.describe(ch)
+	c.cancelledGauge.describe(ch)
+	c.failedGauge.describe(ch)
 	for k := range c.gauges {
 		c.gauges[k].gauge.describe(ch)
 	}
@@ -62,24 +88,31 @@ func (c *Collector) Describe(ch chan<- *prom.Desc) {
 func (c *Collector) Collect(ch chan<- prom.Metric) {
 	c.executionIDGauge.Collect(ch)
 	c.podsGauge.Collect(ch)
+	c.nextGauge.Collect(ch)
+	c.blockedCounter.Collect(ch)
+	c.canaryCounter.Collect(ch)
 	c.versionGauge.Collect(ch)
 
 	c.procErrorGauge.collect(ch)
 	c.durationGauge.collect(ch)
+	c.cancelledGauge.collect(ch)
+	c.failedGauge.collect(ch)
 	for k := range c.gauges {
 		c.gauges[k].gauge.collect(ch)
 	}
 }
 
-// ExecutionStarted metric for new executions.
-func (c *Collector) ExecutionStarted(executionID float64) {
-	c.executionIDGauge.WithLabelValues().Set(executionID)
+// ExecutionStarted metric for new executions of a schedule.
+func (c *Collector) ExecutionStarted(schedule string, executionID float64) {
+	c.executionIDGauge.WithLabelValues(schedule).Set(executionID)
 }
 
 // Prune metrics assigned to the given execution ID.
 func (c *Collector) Prune(executionID string) {
 	c.procErrorGauge.prune(executionID)
 	c.durationGauge.prune(executionID)
+	c.cancelledGauge.prune(executionID)
+	c.failedGauge.prune(executionID)
 	for k := range c.gauges {
 		c.gauges[k].gauge.prune(executionID)
 	}
@@ -131,14 +164,45 @@ func (c *Collector) Duration(node, executionID string, d float64) {
 	}
 }
 
-// Pods record the number of pods started for the current run.
-func (c *Collector) Pods(cnt float64) {
-	g, err := c.podsGauge.GetMetricWithLabelValues()
+// Cancelled record a cancelled job.
+func (c *Collector) Cancelled(node, executionID string) {
+	c.cancelledGauge.withLabelValues(node, executionID).Set(1)
+	if c.latestMetric {
+		c.cancelledGauge.withLabelValues(node, labelValueLatest).Set(1)
+	}
+}
+
+// Failed record a job pod that failed before reaching a terminal phase.
+func (c *Collector) Failed(node, reason, executionID string) {
+	c.failedGauge.withLabelValues(node, reason, executionID).Set(1)
+	if c.latestMetric {
+		c.failedGauge.withLabelValues(node, reason, labelValueLatest).Set(1)
+	}
+}
+
+// Pods record the number of pods started for the current run of a schedule.
+func (c *Collector) Pods(schedule string, cnt float64) {
+	g, err := c.podsGauge.GetMetricWithLabelValues(schedule)
 	if err == nil {
 		g.Set(cnt)
 	}
 }
 
+// NextExecution record the time of the next scheduled execution of a schedule.
+func (c *Collector) NextExecution(schedule string, t time.Time) {
+	c.nextGauge.WithLabelValues(schedule).Set(float64(t.Unix()))
+}
+
+// MaintenanceBlocked record a scheduled execution blocked by a maintenance window.
+func (c *Collector) MaintenanceBlocked(schedule, window, action string) {
+	c.blockedCounter.WithLabelValues(schedule, window, action).Inc()
+}
+
+// CanaryFailed record an execution aborted by a failed canary phase.
+func (c *Collector) CanaryFailed(schedule string) {
+	c.canaryCounter.WithLabelValues(schedule).Inc()
+}
+
 // NewPromCollector create a new prom collector.
 func NewPromCollector(cfg *config.Config) (*Collector, error) {
 	c := &Collector{
@@ -149,7 +213,7 @@ func NewPromCollector(cfg *config.Config) (*Collector, error) {
 	c.executionIDGauge = prom.NewGaugeVec(prom.GaugeOpts{
 		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, currentExecutionMetric),
 		Help: currentExecutionHelp,
-	}, []string{})
+	}, []string{labelSchedule})
 
 	c.procErrorGauge = newMetric(prom.GaugeOpts{
 		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, procErrorMetric),
@@ -161,10 +225,35 @@ func NewPromCollector(cfg *config.Config) (*Collector, error) {
 		Help: durationHelp,
 	}, labelNode, labelExecutionID)
 
+	c.cancelledGauge = newMetric(prom.GaugeOpts{
+		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, cancelledMetric),
+		Help: cancelledHelp,
+	}, labelNode, labelExecutionID)
+
+	c.failedGauge = newMetric(prom.GaugeOpts{
+		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, failedMetric),
+		Help: failedHelp,
+	}, labelNode, labelReason, labelExecutionID)
+
 	c.podsGauge = prom.NewGaugeVec(prom.GaugeOpts{
 		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, podsMetric),
 		Help: podsHelp,
-	}, []string{})
+	}, []string{labelSchedule})
+
+	c.nextGauge = prom.NewGaugeVec(prom.GaugeOpts{
+		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, nextMetric),
+		Help: nextHelp,
+	}, []string{labelSchedule})
+
+	c.blockedCounter = prom.NewCounterVec(prom.CounterOpts{
+		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, blockedMetric),
+		Help: blockedHelp,
+	}, []string{labelSchedule, labelWindow, labelAction})
+
+	c.canaryCounter = prom.NewCounterVec(prom.CounterOpts{
+		Name: fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, canaryMetric),
+		Help: canaryHelp,
+	}, []string{labelSchedule})
 
 	c.versionGauge = prom.NewGaugeVec(prom.GaugeOpts{
 		Name: versionMetric,
@@ -172,9 +261,9 @@ func NewPromCollector(cfg *config.Config) (*Collector, error) {
 	}, []string{config.LabelVersion, config.LabelName, labelPrefix, config.LabelPoolSize, config.LabelReportHistory, labelCron})
 
 	for name, metric := range cfg.Metrics.Gauges {
-		if name == procErrorMetric || name == durationMetric || name == podsMetric {
+		if reserved := reservedMetricNames(); slices.Contains(reserved, name) {
 			return nil, fmt.Errorf("the metric name %q is not allowed, it's one of the reserved names: %v",
-				name, []string{procErrorMetric, durationMetric, podsMetric})
+				name, reserved)
 		}
 
 		labels := enrichLabels(metric.Labels)
@@ -203,6 +292,19 @@ func NewPromCollector(cfg *config.Config) (*Collector, error) {
 	return c, nil
 }
 
+func reservedMetricNames() []string {
+	return []string{
+		procErrorMetric,
+		durationMetric,
+		podsMetric,
+		cancelledMetric,
+		failedMetric,
+		nextMetric,
+		blockedMetric,
+		canaryMetric,
+	}
+}
+
 func enrichLabels(labels []string) []string {
 	out := labels
 	m := make(map[string]bool)
@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -30,6 +31,18 @@ var _ = Describe("metrics", func() {
 			_, err := NewPromCollector(cfg)
 			Ω(err).ShouldNot(HaveOccurred())
 		})
+		It("should allow gauges named like metrics of earlier versions", func() {
+			Ω(reservedMetricNames()).ShouldNot(ContainElement("cancelled"))
+			Ω(reservedMetricNames()).ShouldNot(ContainElement("failed"))
+		})
+		It("should reject gauges with a reserved name", func() {
+			cfg.Metrics = config.Metrics{
+				Prefix: "reserved",
+				Gauges: map[string]config.Metric{failedMetric: {Help: "help"}},
+			}
+			_, err := NewPromCollector(cfg)
+			Ω(err).Should(MatchError(ContainSubstring("reserved names")))
+		})
 	})
 	Context("Metrics", func() {
 		var (
@@ -64,12 +77,12 @@ var _ = Describe("metrics", func() {
 		})
 
 		It("check 'The current execution ID'", func() {
-			pc.ExecutionStarted(executionIDValue)
+			pc.ExecutionStarted("nightly", executionIDValue)
 			checkMetric(
 				pc,
 				currentExecutionHelp,
 				fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, currentExecutionMetric),
-				map[string]string{},
+				map[string]string{"schedule": "nightly"},
 				executionID,
 			)
 		})
@@ -109,23 +122,80 @@ var _ = Describe("metrics", func() {
 			)
 		})
 
+		It("check 'Node with a cancelled job, 1: cancelled'", func() {
+			pc.Cancelled(node, executionID)
+			checkMetric(
+				pc,
+				cancelledHelp,
+				fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, cancelledMetric),
+				map[string]string{"executionID": executionID, "node": node},
+				"1",
+			)
+		})
+
+		It("check 'Node with a job pod that failed before terminating, 1: failed'", func() {
+			pc.Failed(node, "ErrImagePull", executionID)
+			checkMetric(
+				pc,
+				failedHelp,
+				fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, failedMetric),
+				map[string]string{"executionID": executionID, "node": node, "failure_reason": "ErrImagePull"},
+				"1",
+			)
+		})
+
 		It("check error 'The number of Pods started for the last execution'", func() {
 			c := rand.Int() // #nosec G404 ok for tests
 			cnt := float64(c)
-			pc.Pods(cnt)
+			pc.Pods("nightly", cnt)
 			checkMetric(
 				pc,
 				podsHelp,
 				fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, podsMetric),
-				map[string]string{},
+				map[string]string{"schedule": "nightly"},
 				strconv.Itoa(c),
 			)
 		})
 
+		It("check 'The unix timestamp of the next scheduled execution'", func() {
+			next := time.Date(2020, 1, 2, 3, 42, 0, 0, time.UTC)
+			pc.NextExecution("nightly", next)
+			checkMetric(
+				pc,
+				nextHelp,
+				fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, nextMetric),
+				map[string]string{"schedule": "nightly"},
+				strconv.FormatInt(next.Unix(), 10),
+			)
+		})
+
+		It("check 'The number of scheduled executions blocked by a maintenance window'", func() {
+			pc.MaintenanceBlocked("nightly", "freeze", "Skip")
+			pc.MaintenanceBlocked("nightly", "freeze", "Skip")
+			name := fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, blockedMetric)
+			err := testutil.CollectAndCompare(pc, strings.NewReader(fmt.Sprintf(`
+				# HELP %s %s
+				# TYPE %s counter
+				%s{action="Skip",schedule="nightly",window="freeze"} 2
+			`, name, blockedHelp, name, name)), name)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("check 'The number of executions aborted by a failed canary phase'", func() {
+			pc.CanaryFailed("nightly")
+			name := fmt.Sprintf("%s_%s", cfg.Metrics.Prefix, canaryMetric)
+			err := testutil.CollectAndCompare(pc, strings.NewReader(fmt.Sprintf(`
+				# HELP %s %s
+				# TYPE %s counter
+				%s{schedule="nightly"} 1
+			`, name, canaryHelp, name, name)), name)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
 		It("check version", func() {
 			c := rand.Int() // #nosec G404 ok for tests
 			cnt := float64(c)
-			pc.Pods(cnt)
+			pc.Pods("", cnt)
 			checkMetric(
 				pc,
 				versionHelp,
@@ -1,9 +1,9 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/bakito/batch-job-controller/pkg/lifecycle (interfaces: Controller)
+// Source: github.com/bakito/batch-job-controller/pkg/lifecycle (interfaces: Controller,Trigger)
 //
 // Generated by this command:
 //
-//	mockgen -destination pkg/mocks/lifecycle/mock.go github.com/bakito/batch-job-controller/pkg/lifecycle Controller
+//	mockgen -destination pkg/mocks/lifecycle/mock.go github.com/bakito/batch-job-controller/pkg/lifecycle Controller,Trigger
 //
 
 // Package mock_lifecycle is a generated GoMock package.
@@ -71,6 +71,49 @@ func (mr *MockControllerMockRecorder) AllAdded(executionID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllAdded", reflect.TypeOf((*MockController)(nil).AllAdded), executionID)
 }
 
+// Attempt mocks base method.
+func (m *MockController) Attempt(executionID, node string) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Attempt", executionID, node)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Attempt indicates an expected call of Attempt.
+func (mr *MockControllerMockRecorder) Attempt(executionID, node any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Attempt", reflect.TypeOf((*MockController)(nil).Attempt), executionID, node)
+}
+
+// AwaitNodes mocks base method.
+func (m *MockController) AwaitNodes(executionID string, nodes []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AwaitNodes", executionID, nodes)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AwaitNodes indicates an expected call of AwaitNodes.
+func (mr *MockControllerMockRecorder) AwaitNodes(executionID, nodes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AwaitNodes", reflect.TypeOf((*MockController)(nil).AwaitNodes), executionID, nodes)
+}
+
+// Cancel mocks base method.
+func (m *MockController) Cancel(executionID, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cancel", executionID, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Cancel indicates an expected call of Cancel.
+func (mr *MockControllerMockRecorder) Cancel(executionID, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cancel", reflect.TypeOf((*MockController)(nil).Cancel), executionID, reason)
+}
+
 // Config mocks base method.
 func (m *MockController) Config() config.Config {
 	m.ctrl.T.Helper()
@@ -85,6 +128,77 @@ func (mr *MockControllerMockRecorder) Config() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Config", reflect.TypeOf((*MockController)(nil).Config))
 }
 
+// Execution mocks base method.
+func (m *MockController) Execution(executionID string) (*lifecycle.ExecutionStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Execution", executionID)
+	ret0, _ := ret[0].(*lifecycle.ExecutionStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Execution indicates an expected call of Execution.
+func (mr *MockControllerMockRecorder) Execution(executionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Execution", reflect.TypeOf((*MockController)(nil).Execution), executionID)
+}
+
+// Executions mocks base method.
+func (m *MockController) Executions() []*lifecycle.ExecutionStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Executions")
+	ret0, _ := ret[0].([]*lifecycle.ExecutionStatus)
+	return ret0
+}
+
+// Executions indicates an expected call of Executions.
+func (mr *MockControllerMockRecorder) Executions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Executions", reflect.TypeOf((*MockController)(nil).Executions))
+}
+
+// FailedNodes mocks base method.
+func (m *MockController) FailedNodes(executionID string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailedNodes", executionID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailedNodes indicates an expected call of FailedNodes.
+func (mr *MockControllerMockRecorder) FailedNodes(executionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailedNodes", reflect.TypeOf((*MockController)(nil).FailedNodes), executionID)
+}
+
+// FileReceived mocks base method.
+func (m *MockController) FileReceived(executionID, node, fileName string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FileReceived", executionID, node, fileName)
+}
+
+// FileReceived indicates an expected call of FileReceived.
+func (mr *MockControllerMockRecorder) FileReceived(executionID, node, fileName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FileReceived", reflect.TypeOf((*MockController)(nil).FileReceived), executionID, node, fileName)
+}
+
+// Finished mocks base method.
+func (m *MockController) Finished(executionID string) (<-chan struct{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Finished", executionID)
+	ret0, _ := ret[0].(<-chan struct{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Finished indicates an expected call of Finished.
+func (mr *MockControllerMockRecorder) Finished(executionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Finished", reflect.TypeOf((*MockController)(nil).Finished), executionID)
+}
+
 // Has mocks base method.
 func (m *MockController) Has(node, executionID string) bool {
 	m.ctrl.T.Helper()
@@ -99,18 +213,61 @@ func (mr *MockControllerMockRecorder) Has(node, executionID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Has", reflect.TypeOf((*MockController)(nil).Has), node, executionID)
 }
 
+// LinkRerun mocks base method.
+func (m *MockController) LinkRerun(originalID, executionID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkRerun", originalID, executionID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkRerun indicates an expected call of LinkRerun.
+func (mr *MockControllerMockRecorder) LinkRerun(originalID, executionID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkRerun", reflect.TypeOf((*MockController)(nil).LinkRerun), originalID, executionID)
+}
+
 // NewExecution mocks base method.
-func (m *MockController) NewExecution(nbrOrJobs int) string {
+func (m *MockController) NewExecution(schedule string, nbrOrJobs int) string {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "NewExecution", nbrOrJobs)
+	ret := m.ctrl.Call(m, "NewExecution", schedule, nbrOrJobs)
 	ret0, _ := ret[0].(string)
 	return ret0
 }
 
 // NewExecution indicates an expected call of NewExecution.
-func (mr *MockControllerMockRecorder) NewExecution(nbrOrJobs any) *gomock.Call {
+func (mr *MockControllerMockRecorder) NewExecution(schedule, nbrOrJobs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewExecution", reflect.TypeOf((*MockController)(nil).NewExecution), schedule, nbrOrJobs)
+}
+
+// NewPartialExecution mocks base method.
+func (m *MockController) NewPartialExecution(schedule string, nbrOrJobs int) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewPartialExecution", schedule, nbrOrJobs)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// NewPartialExecution indicates an expected call of NewPartialExecution.
+func (mr *MockControllerMockRecorder) NewPartialExecution(schedule, nbrOrJobs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewPartialExecution", reflect.TypeOf((*MockController)(nil).NewPartialExecution), schedule, nbrOrJobs)
+}
+
+// PodFailed mocks base method.
+func (m *MockController) PodFailed(executionID, node, reason string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PodFailed", executionID, node, reason)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PodFailed indicates an expected call of PodFailed.
+func (mr *MockControllerMockRecorder) PodFailed(executionID, node, reason any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewExecution", reflect.TypeOf((*MockController)(nil).NewExecution), nbrOrJobs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PodFailed", reflect.TypeOf((*MockController)(nil).PodFailed), executionID, node, reason)
 }
 
 // PodTerminated mocks base method.
@@ -127,6 +284,20 @@ func (mr *MockControllerMockRecorder) PodTerminated(executionID, node, phase any
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PodTerminated", reflect.TypeOf((*MockController)(nil).PodTerminated), executionID, node, phase)
 }
 
+// Progress mocks base method.
+func (m *MockController) Progress() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Progress")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Progress indicates an expected call of Progress.
+func (mr *MockControllerMockRecorder) Progress() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Progress", reflect.TypeOf((*MockController)(nil).Progress))
+}
+
 // ReportReceived mocks base method.
 func (m *MockController) ReportReceived(executionID, node string, processingError error, results metrics.Results) {
 	m.ctrl.T.Helper()
@@ -138,3 +309,56 @@ func (mr *MockControllerMockRecorder) ReportReceived(executionID, node, processi
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportReceived", reflect.TypeOf((*MockController)(nil).ReportReceived), executionID, node, processingError, results)
 }
+
+// Restore mocks base method.
+func (m *MockController) Restore(executionID string, pods []lifecycle.RestoredPod) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", executionID, pods)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockControllerMockRecorder) Restore(executionID, pods any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockController)(nil).Restore), executionID, pods)
+}
+
+// MockTrigger is a mock of Trigger interface.
+type MockTrigger struct {
+	ctrl     *gomock.Controller
+	recorder *MockTriggerMockRecorder
+	isgomock struct{}
+}
+
+// MockTriggerMockRecorder is the mock recorder for MockTrigger.
+type MockTriggerMockRecorder struct {
+	mock *MockTrigger
+}
+
+// NewMockTrigger creates a new mock instance.
+func NewMockTrigger(ctrl *gomock.Controller) *MockTrigger {
+	mock := &MockTrigger{ctrl: ctrl}
+	mock.recorder = &MockTriggerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTrigger) EXPECT() *MockTriggerMockRecorder {
+	return m.recorder
+}
+
+// Trigger mocks base method.
+func (m *MockTrigger) Trigger(opts lifecycle.TriggerOptions) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trigger", opts)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Trigger indicates an expected call of Trigger.
+func (mr *MockTriggerMockRecorder) Trigger(opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trigger", reflect.TypeOf((*MockTrigger)(nil).Trigger), opts)
+}
@@ -0,0 +1,270 @@
+package target
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// KindNode the kind of node targets.
+	KindNode = "Node"
+	// KindEntry the kind of targets of an entry of a target list.
+	KindEntry = "Entry"
+
+	entryName   = "name"
+	entryLabels = "labels"
+)
+
+// Target an object the jobs of an execution fan out over, one job per target.
+type Target struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	// Annotations the annotations of a node or an object
+	Annotations map[string]string
+	// Allocatable the allocatable resources of a node
+	Allocatable corev1.ResourceList
+	// Fields the fields of an entry of a target list
+	Fields map[string]any
+}
+
+// FromNode get the target of a node.
+func FromNode(node corev1.Node) Target {
+	return Target{
+		Kind:        KindNode,
+		Name:        node.Name,
+		Labels:      node.Labels,
+		Annotations: node.Annotations,
+		Allocatable: node.Status.Allocatable,
+	}
+}
+
+// ID get the identity of the target. It is used in place of the node name in the callbacks, file names and metrics.
+// The identity of a namespaced target is <namespace>.<name>, the name otherwise.
+func (t Target) ID() string {
+	if t.Namespace == "" {
+		return t.Name
+	}
+	return t.Namespace + "." + t.Name
+}
+
+// IsNode returns true if the target is a node, its job pod runs on the node.
+func (t Target) IsNode() bool {
+	return t.Kind == KindNode
+}
+
+// IDs get the identities of the targets.
+func IDs(targets []Target) []string {
+	ids := make([]string, len(targets))
+	for i := range targets {
+		ids[i] = targets[i].ID()
+	}
+	return ids
+}
+
+// Source lists the targets of an execution.
+type Source interface {
+	// Targets list the current targets
+	Targets(ctx context.Context) ([]Target, error)
+}
+
+// Nodes get a source of the usable nodes matching the selector labels and the filter.
+func Nodes(cl client.Reader, selector map[string]string, runOnUnscheduledNodes bool, filter func(corev1.Node) bool) Source {
+	return &nodes{client: cl, selector: selector, runOnUnscheduledNodes: runOnUnscheduledNodes, filter: filter}
+}
+
+type nodes struct {
+	client                client.Reader
+	selector              map[string]string
+	runOnUnscheduledNodes bool
+	filter                func(corev1.Node) bool
+}
+
+func (s *nodes) Targets(ctx context.Context) ([]Target, error) {
+	nodeList := &corev1.NodeList{}
+	if err := s.client.List(ctx, nodeList, client.MatchingLabels(s.selector)); err != nil {
+		return nil, err
+	}
+	var targets []Target
+	for _, n := range nodeList.Items {
+		if IsUsable(n, s.runOnUnscheduledNodes) && (s.filter == nil || s.filter(n)) {
+			targets = append(targets, FromNode(n))
+		}
+	}
+	return targets, nil
+}
+
+// Objects get a source of the objects of the given kind matching the selector.
+// The objects of all namespaces are listed if the namespace is empty.
+func Objects(cl client.Reader, gvk schema.GroupVersionKind, namespace string, selector labels.Selector) Source {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	return &objects{client: cl, gvk: gvk, namespace: namespace, selector: selector}
+}
+
+type objects struct {
+	client    client.Reader
+	gvk       schema.GroupVersionKind
+	namespace string
+	selector  labels.Selector
+}
+
+func (s *objects) Targets(ctx context.Context) ([]Target, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(s.gvk.GroupVersion().WithKind(s.gvk.Kind + "List"))
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: s.selector}}
+	if s.namespace != "" {
+		opts = append(opts, client.InNamespace(s.namespace))
+	}
+	if err := s.client.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	targets := make([]Target, len(list.Items))
+	for i, o := range list.Items {
+		targets[i] = Target{
+			Kind:        s.gvk.Kind,
+			Name:        o.GetName(),
+			Namespace:   o.GetNamespace(),
+			Labels:      o.GetLabels(),
+			Annotations: o.GetAnnotations(),
+		}
+	}
+	return targets, nil
+}
+
+// FromEntries get the targets of the entries of a target list. An entry is either the name of the target, or an object
+// with the name of the target and further fields. The labels of an object are used as labels of the target.
+func FromEntries(entries []any) ([]Target, error) {
+	targets := make([]Target, len(entries))
+	names := make(map[string]bool)
+	for i, e := range entries {
+		t := Target{Kind: KindEntry, Fields: map[string]any{}}
+		switch v := e.(type) {
+		case string:
+			t.Name = v
+			t.Fields[entryName] = v
+		case map[string]any:
+			t.Name, _ = v[entryName].(string)
+			t.Fields = v
+			if l, ok := v[entryLabels].(map[string]any); ok {
+				t.Labels = make(map[string]string, len(l))
+				for key, value := range l {
+					t.Labels[key] = fmt.Sprint(value)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("target %d must be a name or an object, not %T", i, e)
+		}
+		// the name is used in pod names, callback urls and file names
+		if errs := validation.IsDNS1123Subdomain(t.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid name %q of target %d: %s", t.Name, i, strings.Join(errs, ", "))
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("duplicate target name %q", t.Name)
+		}
+		names[t.Name] = true
+		targets[i] = t
+	}
+	return targets, nil
+}
+
+// Static get a source of a static target list.
+func Static(entries []any) Source {
+	return &static{entries: entries}
+}
+
+type static struct {
+	entries []any
+}
+
+func (s *static) Targets(context.Context) ([]Target, error) {
+	return FromEntries(s.entries)
+}
+
+// ConfigMap get a source of a yaml or json target list in a key of a configmap.
+func ConfigMap(cl client.Reader, namespace, name, key string) Source {
+	return &configMap{client: cl, namespace: namespace, name: name, key: key}
+}
+
+type configMap struct {
+	client    client.Reader
+	namespace string
+	name      string
+	key       string
+}
+
+func (s *configMap) Targets(ctx context.Context) ([]Target, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: s.namespace, Name: s.name}, cm); err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[s.key]
+	if !ok {
+		return nil, fmt.Errorf("could not find targets %q in configmap %q", s.key, s.name)
+	}
+	var entries []any
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(data), 20).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("could not read targets %q in configmap %q: %w", s.key, s.name, err)
+	}
+	return FromEntries(entries)
+}
+
+// HTTP get a source of a json target list responded by an http endpoint.
+func HTTP(url string, headers map[string]string, timeout time.Duration) Source {
+	return &httpSource{
+		url:    url,
+		client: resty.New().SetHeader("Accept", "application/json").SetHeaders(headers).SetTimeout(timeout),
+	}
+}
+
+type httpSource struct {
+	url    string
+	client *resty.Client
+}
+
+func (s *httpSource) Targets(ctx context.Context) ([]Target, error) {
+	resp, err := s.client.R().SetContext(ctx).Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("could not get targets from %q: %s", s.url, resp.Status())
+	}
+	var entries []any
+	if err := json.Unmarshal(resp.Body(), &entries); err != nil {
+		return nil, fmt.Errorf("could not read targets from %q: %w", s.url, err)
+	}
+	return FromEntries(entries)
+}
+
+// IsUsable check if jobs can run on the node.
+func IsUsable(node corev1.Node, runOnUnscheduledNodes bool) bool {
+	if !runOnUnscheduledNodes && node.Spec.Unschedulable {
+		return false
+	}
+	return IsReady(node)
+}
+
+// IsReady check if the node has a ready condition.
+func IsReady(node corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	gm "go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mockclient "github.com/bakito/batch-job-controller/pkg/mocks/client"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Target", func() {
+	var (
+		mockCtrl   *gm.Controller
+		mockClient *mockclient.MockClient
+		ctx        context.Context
+		ready      corev1.NodeCondition
+	)
+	BeforeEach(func() {
+		mockCtrl = gm.NewController(GinkgoT())
+		mockClient = mockclient.NewMockClient(mockCtrl)
+		ctx = context.TODO()
+		ready = corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue}
+	})
+
+	Context("ID", func() {
+		It("should return the name of a cluster scoped target", func() {
+			Ω(Target{Kind: KindNode, Name: "node-a"}.ID()).Should(Equal("node-a"))
+		})
+		It("should return the namespace and name of a namespaced target", func() {
+			Ω(Target{Kind: "PersistentVolumeClaim", Name: "data", Namespace: "app"}.ID()).Should(Equal("app.data"))
+		})
+		It("should return the ids of the targets", func() {
+			Ω(IDs([]Target{{Name: "a"}, {Name: "b", Namespace: "ns"}})).Should(Equal([]string{"a", "ns.b"}))
+		})
+	})
+
+	Context("Nodes", func() {
+		It("should list the usable nodes matching the filter", func() {
+			mockClient.EXPECT().List(ctx, gm.AssignableToTypeOf(&corev1.NodeList{}), client.MatchingLabels{"role": "worker"}).
+				Do(func(_ context.Context, list *corev1.NodeList, _ ...client.ListOption) error {
+					list.Items = []corev1.Node{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{"role": "worker"}},
+							Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{ready}},
+						},
+						{ObjectMeta: metav1.ObjectMeta{Name: "node-b"}},
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "node-c"},
+							Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{ready}},
+						},
+					}
+					return nil
+				})
+
+			s := Nodes(mockClient, map[string]string{"role": "worker"}, false, func(n corev1.Node) bool {
+				return n.Name != "node-c"
+			})
+			targets, err := s.Targets(ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(targets).Should(Equal([]Target{{Kind: KindNode, Name: "node-a", Labels: map[string]string{"role": "worker"}}}))
+		})
+		It("should return the list error", func() {
+			mockClient.EXPECT().List(ctx, gm.Any(), gm.Any()).Return(errors.New("list failed"))
+
+			_, err := Nodes(mockClient, nil, false, nil).Targets(ctx)
+			Ω(err).Should(MatchError("list failed"))
+		})
+	})
+
+	Context("Objects", func() {
+		var gvk schema.GroupVersionKind
+		BeforeEach(func() {
+			gvk = schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}
+		})
+		It("should list the objects of the kind", func() {
+			selector, err := labels.Parse("tier=db")
+			Ω(err).ShouldNot(HaveOccurred())
+			mockClient.EXPECT().List(ctx, gm.AssignableToTypeOf(&unstructured.UnstructuredList{}),
+				client.MatchingLabelsSelector{Selector: selector}, client.InNamespace("app")).
+				Do(func(_ context.Context, list *unstructured.UnstructuredList, _ ...client.ListOption) error {
+					Ω(list.GroupVersionKind()).Should(Equal(gvk.GroupVersion().WithKind("PersistentVolumeClaimList")))
+					o := unstructured.Unstructured{}
+					o.SetName("data")
+					o.SetNamespace("app")
+					o.SetLabels(map[string]string{"tier": "db"})
+					list.Items = []unstructured.Unstructured{o}
+					return nil
+				})
+
+			targets, err := Objects(mockClient, gvk, "app", selector).Targets(ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(targets).Should(Equal([]Target{{
+				Kind:      "PersistentVolumeClaim",
+				Name:      "data",
+				Namespace: "app",
+				Labels:    map[string]string{"tier": "db"},
+			}}))
+			Ω(targets[0].IsNode()).Should(BeFalse())
+		})
+		It("should list the objects of all namespaces", func() {
+			mockClient.EXPECT().List(ctx, gm.Any(), client.MatchingLabelsSelector{Selector: labels.Everything()})
+
+			targets, err := Objects(mockClient, gvk, "", nil).Targets(ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(targets).Should(BeEmpty())
+		})
+	})
+
+	Context("FromEntries", func() {
+		It("should get the targets of names and objects", func() {
+			targets, err := FromEntries([]any{
+				"shard-1",
+				map[string]any{"name": "shard-2", "host": "db-2.example.com", "labels": map[string]any{"zone": "a"}},
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(targets).Should(HaveLen(2))
+			Ω(targets[0]).Should(Equal(Target{Kind: KindEntry, Name: "shard-1", Fields: map[string]any{"name": "shard-1"}}))
+			Ω(targets[1].ID()).Should(Equal("shard-2"))
+			Ω(targets[1].Fields["host"]).Should(Equal("db-2.example.com"))
+			Ω(targets[1].Labels).Should(Equal(map[string]string{"zone": "a"}))
+			Ω(targets[1].IsNode()).Should(BeFalse())
+		})
+		It("should return an error if an entry is invalid", func() {
+			_, err := FromEntries([]any{1})
+			Ω(err).Should(MatchError(ContainSubstring("must be a name or an object")))
+			_, err = FromEntries([]any{map[string]any{"host": "db"}})
+			Ω(err).Should(MatchError(ContainSubstring("invalid name")))
+			_, err = FromEntries([]any{"Shard_1"})
+			Ω(err).Should(MatchError(ContainSubstring("invalid name")))
+			_, err = FromEntries([]any{"shard-1", map[string]any{"name": "shard-1"}})
+			Ω(err).Should(MatchError(ContainSubstring("duplicate target name")))
+		})
+	})
+
+	Context("Static", func() {
+		It("should list the static targets", func() {
+			targets, err := Static([]any{"shard-1", "shard-2"}).Targets(ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(IDs(targets)).Should(Equal([]string{"shard-1", "shard-2"}))
+		})
+	})
+
+	Context("ConfigMap", func() {
+		It("should list the targets of the configmap key", func() {
+			key := client.ObjectKey{Namespace: "ns", Name: "shards"}
+			mockClient.EXPECT().Get(ctx, key, gm.AssignableToTypeOf(&corev1.ConfigMap{})).
+				Do(func(_ context.Context, _ client.ObjectKey, cm *corev1.ConfigMap, _ ...client.GetOption) error {
+					cm.Data = map[string]string{"targets.yaml": "- shard-1\n- name: shard-2\n  port: 5432\n"}
+					return nil
+				})
+
+			targets, err := ConfigMap(mockClient, "ns", "shards", "targets.yaml").Targets(ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(IDs(targets)).Should(Equal([]string{"shard-1", "shard-2"}))
+			Ω(targets[1].Fields).Should(HaveKey("port"))
+		})
+		It("should return an error if the key is missing", func() {
+			mockClient.EXPECT().Get(ctx, gm.Any(), gm.AssignableToTypeOf(&corev1.ConfigMap{}))
+
+			_, err := ConfigMap(mockClient, "ns", "shards", "targets.yaml").Targets(ctx)
+			Ω(err).Should(MatchError(ContainSubstring("could not find targets")))
+		})
+	})
+
+	Context("HTTP", func() {
+		It("should list the targets of the response", func() {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Ω(r.Header.Get("Authorization")).Should(Equal("Bearer token"))
+				_, _ = w.Write([]byte(`["shard-1", {"name": "shard-2", "url": "https://shard-2.example.com"}]`))
+			}))
+			defer srv.Close()
+
+			targets, err := HTTP(srv.URL, map[string]string{"Authorization": "Bearer token"}, time.Second).Targets(ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(IDs(targets)).Should(Equal([]string{"shard-1", "shard-2"}))
+			Ω(targets[1].Fields["url"]).Should(Equal("https://shard-2.example.com"))
+		})
+		It("should return an error if the request failed", func() {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer srv.Close()
+
+			_, err := HTTP(srv.URL, nil, time.Second).Targets(ctx)
+			Ω(err).Should(MatchError(ContainSubstring("could not get targets")))
+		})
+	})
+
+	Context("IsUsable", func() {
+		var (
+			node                  corev1.Node
+			runOnUnscheduledNodes bool
+		)
+		BeforeEach(func() {
+			node = corev1.Node{
+				Spec:   corev1.NodeSpec{},
+				Status: corev1.NodeStatus{},
+			}
+		})
+		It("should return false if unschedulable", func() {
+			node.Spec.Unschedulable = true
+			runOnUnscheduledNodes = false
+			Ω(IsUsable(node, runOnUnscheduledNodes)).Should(BeFalse())
+		})
+		It("should return true if node ready", func() {
+			node.Spec.Unschedulable = false
+			node.Status.Conditions = []corev1.NodeCondition{ready}
+			runOnUnscheduledNodes = false
+			Ω(IsUsable(node, runOnUnscheduledNodes)).Should(BeTrue())
+		})
+		It("should return false if node not ready", func() {
+			node.Spec.Unschedulable = false
+			node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}
+			runOnUnscheduledNodes = false
+			Ω(IsUsable(node, runOnUnscheduledNodes)).Should(BeFalse())
+		})
+		It("should return false if no conditions are set", func() {
+			node.Spec.Unschedulable = false
+			runOnUnscheduledNodes = false
+			Ω(IsUsable(node, runOnUnscheduledNodes)).Should(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-resty/resty/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/bakito/batch-job-controller/pkg/config"
+)
+
+// Payload the payload sent to the webhooks.
+type Payload struct {
+	Event string `json:"event"`
+	// Name the name of the controller
+	Name        string     `json:"name"`
+	ExecutionID string     `json:"executionID"`
+	Started     time.Time  `json:"started"`
+	Finished    *time.Time `json:"finished,omitempty"`
+	Cancelled   bool       `json:"cancelled,omitempty"`
+	// Nodes the number of nodes of the execution
+	Nodes int `json:"nodes"`
+	// Succeeded the number of nodes with a succeeded pod
+	Succeeded int `json:"succeeded"`
+	// Failed the number of nodes with a pod that did not succeed
+	Failed int `json:"failed"`
+	// MissingReport the number of nodes that did not send a report
+	MissingReport int `json:"missingReport"`
+	// ReportURL the link to the report directory of the execution on the static file server
+	ReportURL string `json:"reportURL,omitempty"`
+}
+
+// Notifier notifies the configured webhooks.
+type Notifier struct {
+	name      string
+	reportURL string
+	hooks     []*hook
+	log       logr.Logger
+}
+
+type hook struct {
+	config.Webhook
+	template *template.Template
+	client   *resty.Client
+}
+
+// New create a new notifier for the webhooks of the config.
+func New(cfg *config.Config) *Notifier {
+	n := &Notifier{
+		name:      cfg.Name,
+		reportURL: strings.TrimSuffix(cfg.ReportURL, "/"),
+		log:       ctrl.Log.WithName("webhook"),
+	}
+	for _, wh := range cfg.Webhooks {
+		h := &hook{Webhook: wh}
+		if wh.Template != "" {
+			t, err := template.New("webhook").Parse(wh.Template)
+			if err != nil {
+				n.log.WithValues("url", wh.URL).Error(err, "invalid template, webhook is disabled")
+				continue
+			}
+			h.template = t
+		}
+		retries := config.DefaultWebhookRetries
+		if wh.Retries != nil {
+			retries = *wh.Retries
+		}
+		timeout := config.DefaultWebhookTimeout
+		if wh.Timeout.Duration > 0 {
+			timeout = wh.Timeout.Duration
+		}
+		h.client = resty.New().
+			SetHeader("Content-Type", "application/json; charset=utf-8").
+			SetHeaders(wh.Headers).
+			SetTimeout(timeout).
+			SetRetryCount(retries).
+			AddRetryCondition(func(resp *resty.Response, _ error) bool {
+				return resp != nil && (resp.StatusCode() >= http.StatusInternalServerError ||
+					resp.StatusCode() == http.StatusTooManyRequests)
+			})
+		n.hooks = append(n.hooks, h)
+	}
+	return n
+}
+
+// Notify send the payload to all webhooks registered for its event. The requests are sent asynchronously.
+func (n *Notifier) Notify(p Payload) {
+	if len(n.hooks) == 0 {
+		return
+	}
+	p.Name = n.name
+	if n.reportURL != "" {
+		p.ReportURL = fmt.Sprintf("%s/%s/", n.reportURL, p.ExecutionID)
+	}
+	for _, h := range n.hooks {
+		if h.Wants(p.Event) {
+			go n.send(h, p)
+		}
+	}
+}
+
+func (n *Notifier) send(h *hook, p Payload) {
+	l := n.log.WithValues("url", h.URL, "event", p.Event, "id", p.ExecutionID)
+
+	req := h.client.R()
+	if h.template != nil {
+		var buf bytes.Buffer
+		if err := h.template.Execute(&buf, p); err != nil {
+			l.Error(err, "could not render webhook template")
+			return
+		}
+		req.SetBody(buf.Bytes())
+	} else {
+		req.SetBody(p)
+	}
+
+	resp, err := req.Post(h.URL)
+	if err != nil {
+		l.Error(err, "webhook request failed")
+		return
+	}
+	if resp.IsError() {
+		l.Error(fmt.Errorf("unexpected status %q", resp.Status()), "webhook request failed", "body", resp.String())
+		return
+	}
+	l.V(2).Info("webhook notified")
+}
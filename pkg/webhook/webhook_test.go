@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bakito/batch-job-controller/pkg/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Webhook", func() {
+	var (
+		srv      *httptest.Server
+		mux      sync.Mutex
+		bodies   []string
+		headers  []http.Header
+		status   atomic.Int32
+		requests atomic.Int32
+		cfg      *config.Config
+	)
+	BeforeEach(func() {
+		bodies = nil
+		headers = nil
+		status.Store(http.StatusOK)
+		requests.Store(0)
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			requests.Add(1)
+			mux.Lock()
+			bodies = append(bodies, string(b))
+			headers = append(headers, r.Header)
+			mux.Unlock()
+			w.WriteHeader(int(status.Load()))
+		}))
+		DeferCleanup(srv.Close)
+		cfg = &config.Config{
+			Name:      "test",
+			ReportURL: "https://reports.example.com/",
+		}
+	})
+	received := func() []string {
+		mux.Lock()
+		defer mux.Unlock()
+		return append([]string{}, bodies...)
+	}
+
+	It("should send the payload as json", func() {
+		cfg.Webhooks = []config.Webhook{{URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}}}
+		New(cfg).Notify(Payload{Event: config.WebhookEventStarted, ExecutionID: "202001021504", Nodes: 2})
+
+		Eventually(received).Should(HaveLen(1))
+		p := &Payload{}
+		Ω(json.Unmarshal([]byte(received()[0]), p)).ShouldNot(HaveOccurred())
+		Ω(p.Event).Should(Equal(config.WebhookEventStarted))
+		Ω(p.Name).Should(Equal("test"))
+		Ω(p.ExecutionID).Should(Equal("202001021504"))
+		Ω(p.Nodes).Should(Equal(2))
+		Ω(p.ReportURL).Should(Equal("https://reports.example.com/202001021504/"))
+		mux.Lock()
+		Ω(headers[0].Get("X-Token")).Should(Equal("secret"))
+		mux.Unlock()
+	})
+
+	It("should render the template", func() {
+		cfg.Webhooks = []config.Webhook{{
+			URL:      srv.URL,
+			Template: `{"text": "{{ .Name }} {{ .ExecutionID }}: {{ .Failed }} of {{ .Nodes }} nodes failed"}`,
+		}}
+		New(cfg).Notify(Payload{Event: config.WebhookEventFailed, ExecutionID: "1", Nodes: 3, Failed: 1})
+
+		Eventually(received).Should(ConsistOf(`{"text": "test 1: 1 of 3 nodes failed"}`))
+	})
+
+	It("should only notify the subscribed events", func() {
+		cfg.Webhooks = []config.Webhook{{URL: srv.URL, Events: []string{config.WebhookEventFailed}}}
+		n := New(cfg)
+		n.Notify(Payload{Event: config.WebhookEventFinished, ExecutionID: "1"})
+		n.Notify(Payload{Event: config.WebhookEventFailed, ExecutionID: "1"})
+
+		Eventually(received).Should(HaveLen(1))
+		Consistently(received, 100*time.Millisecond).Should(HaveLen(1))
+		Ω(received()[0]).Should(ContainSubstring(`"event":"failed"`))
+	})
+
+	It("should retry failed requests", func() {
+		status.Store(http.StatusServiceUnavailable)
+		retries := 2
+		cfg.Webhooks = []config.Webhook{{URL: srv.URL, Retries: &retries}}
+		New(cfg).Notify(Payload{Event: config.WebhookEventStarted, ExecutionID: "1"})
+
+		Eventually(requests.Load, 5*time.Second).Should(Equal(int32(3)))
+		Consistently(requests.Load, 500*time.Millisecond).Should(Equal(int32(3)))
+	})
+
+	It("should skip webhooks with an invalid template", func() {
+		cfg.Webhooks = []config.Webhook{{URL: srv.URL, Template: "{{ .Foo"}}
+		Ω(New(cfg).hooks).Should(BeEmpty())
+	})
+})